@@ -0,0 +1,231 @@
+package bvh
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/downflux/go-bvh/bvh/op/remove"
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-bvh/internal/cache/node"
+	"github.com/downflux/go-bvh/internal/cache/op/balance"
+	"github.com/downflux/go-geometry/nd/hyperrectangle"
+
+	cid "github.com/downflux/go-bvh/internal/cache/id"
+)
+
+// InsertBatch adds several objects into the tree as per Insert. Unlike calling
+// Insert for each object, the ancestors of the added objects are only refit and
+// rebalanced once, after all objects have been added. As with Insert, the AVL
+// height constraint is maintained throughout the batch under the
+// BalanceBrianNoyamaNoDF and BalanceAVL strategies, though the tree cost may be
+// slightly higher than if the objects were added one at a time.
+//
+// Objects are added in increasing ID order, which ensures a deterministic tree
+// layout. InsertBatch returns the errors of any objects which could not be
+// added, keyed by object ID, or nil if all objects were added.
+func (t *T) InsertBatch(data map[id.ID]hyperrectangle.R) map[id.ID]error {
+	b := t.batch()
+	for _, x := range keys(data) {
+		if _, ok := t.data[x]; ok {
+			b.errorf(x, "cannot insert a duplicate node %v", x)
+			continue
+		}
+		b.add(x, data[x])
+	}
+	return b.flush()
+}
+
+// UpdateBatch updates the AABBs of several objects as per Update. As with
+// InsertBatch, the ancestors of any reinserted objects are only rebalanced once.
+func (t *T) UpdateBatch(data map[id.ID]hyperrectangle.R) map[id.ID]error {
+	b := t.batch()
+	for _, x := range keys(data) {
+		if _, ok := t.data[x]; !ok {
+			b.errorf(x, "cannot update a non-existent node %v", x)
+			continue
+		}
+
+		aabb := data[x]
+		if hyperrectangle.Contains(t.c.GetOrDie(t.nodes[x]).AABB().R(), aabb) {
+			t.data[x].M().Copy(aabb)
+			t.setBounds(x, nil)
+			continue
+		}
+
+		b.unlink(x)
+		b.add(x, aabb)
+	}
+	return b.flush()
+}
+
+// RemoveBatch removes several objects from the tree as per Remove. As with
+// InsertBatch, the ancestors of the removed objects are only rebalanced once.
+func (t *T) RemoveBatch(xs []id.ID) map[id.ID]error {
+	ys := make([]id.ID, len(xs))
+	copy(ys, xs)
+	sort.Slice(ys, func(i, j int) bool { return ys[i] < ys[j] })
+
+	b := t.batch()
+	for _, x := range ys {
+		if _, ok := t.data[x]; !ok {
+			b.errorf(x, "cannot remove a non-existent node %v", x)
+			continue
+		}
+
+		b.unlink(x)
+
+		delete(t.data, x)
+		delete(t.bounds, x)
+		delete(t.objects, x)
+		delete(t.static, x)
+	}
+	return b.flush()
+}
+
+func keys(data map[id.ID]hyperrectangle.R) []id.ID {
+	xs := make([]id.ID, 0, len(data))
+	for x := range data {
+		xs = append(xs, x)
+	}
+	sort.Slice(xs, func(i, j int) bool { return xs[i] < xs[j] })
+	return xs
+}
+
+// batch tracks the nodes which need to be updated after a sequence of
+// mutations.
+type batch struct {
+	t *T
+
+	// dirty is the set of nodes whose ancestors need to be updated and
+	// rebalanced. A node in this set may have been freed by a later
+	// mutation in the batch, in which case the node is skipped.
+	dirty map[cid.ID]struct{}
+	errs  map[id.ID]error
+}
+
+func (t *T) batch() *batch {
+	return &batch{
+		t:     t,
+		dirty: make(map[cid.ID]struct{}),
+	}
+}
+
+func (b *batch) errorf(x id.ID, format string, args ...any) {
+	if b.errs == nil {
+		b.errs = make(map[id.ID]error)
+	}
+	b.errs[x] = fmt.Errorf(format, args...)
+}
+
+// add inserts an object into the tree as per T.add, but defers updating the
+// ancestor nodes until flush.
+func (b *batch) add(x id.ID, aabb hyperrectangle.R) {
+	t := b.t
+	t.set(x, aabb, nil)
+
+	root, mutations := t.insert.Add(t.c, t.root, t.bounds, t.static, x, t.tolerance)
+	t.root = root.ID()
+	for _, n := range mutations {
+		b.dirty[n.ID()] = struct{}{}
+		for x := range n.Leaves() {
+			t.nodes[x] = n.ID()
+		}
+	}
+}
+
+// unlink removes an object from the tree nodes as per T.unlink, but defers
+// updating the ancestor nodes until flush.
+func (b *batch) unlink(x id.ID) {
+	t := b.t
+
+	n := remove.Detach(t.c, t.nodes[x], x)
+	delete(t.nodes, x)
+
+	if n == nil {
+		t.root = cid.IDInvalid
+		return
+	}
+
+	b.dirty[n.ID()] = struct{}{}
+
+	// Maintain the AVL height constraint of the ancestor nodes. The
+	// ancestor AABBs are not shrunk until flush, but will still contain
+	// all remaining objects.
+	//
+	// Unlike an insert, a delete may unbalance an ancestor whose height is
+	// unchanged, and so the ancestor must be rebalanced before checking if
+	// the traversal may stop.
+	for m := n; m != nil; m = m.Parent() {
+		h := m.Height()
+		node.SetHeight(m)
+		m = balance.AVL(m)
+		if m != n && m.Height() == h {
+			break
+		}
+	}
+
+	for n.Parent() != nil {
+		n = n.Parent()
+	}
+	t.root = n.ID()
+}
+
+// flush updates the caches of all dirty nodes and their ancestors bottom-up,
+// and rebalances each of these nodes exactly once. flush returns the errors
+// encountered during the batch.
+func (b *batch) flush() map[id.ID]error {
+	t := b.t
+	if len(b.dirty) == 0 {
+		return b.errs
+	}
+	t.hasBaseline = false
+
+	depths := make(map[cid.ID]int, len(b.dirty))
+	var depth func(n node.N) int
+	depth = func(n node.N) int {
+		if d, ok := depths[n.ID()]; ok {
+			return d
+		}
+		d := 0
+		if p := n.Parent(); p != nil {
+			d = depth(p) + 1
+		}
+		depths[n.ID()] = d
+		return d
+	}
+
+	var ns []node.N
+	visited := make(map[cid.ID]struct{}, len(b.dirty))
+	for x := range b.dirty {
+		n, ok := t.c.Get(x)
+		if !ok {
+			continue
+		}
+		for m := n; m != nil; m = m.Parent() {
+			if _, ok := visited[m.ID()]; ok {
+				break
+			}
+			visited[m.ID()] = struct{}{}
+			depth(m)
+			ns = append(ns, m)
+		}
+	}
+
+	// Update the deepest nodes first, which ensures the children of a node
+	// are valid by the time the node itself is updated.
+	sort.Slice(ns, func(i, j int) bool {
+		if di, dj := depths[ns[i].ID()], depths[ns[j].ID()]; di != dj {
+			return di > dj
+		}
+		return ns[i].ID() < ns[j].ID()
+	})
+	for _, n := range ns {
+		node.SetAABB(n, t.bounds, t.static, t.tolerance)
+		node.SetHeight(n)
+		if !n.IsLeaf() {
+			t.insert.Balance(n)
+		}
+	}
+
+	return b.errs
+}
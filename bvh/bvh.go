@@ -3,12 +3,21 @@ package bvh
 
 import (
 	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
 
+	"github.com/downflux/go-bvh/bvh/cursor"
 	"github.com/downflux/go-bvh/bvh/op/insert"
+	"github.com/downflux/go-bvh/bvh/op/optimize"
 	"github.com/downflux/go-bvh/bvh/op/query"
 	"github.com/downflux/go-bvh/bvh/op/remove"
+	"github.com/downflux/go-bvh/bvh/snapshot"
 	"github.com/downflux/go-bvh/id"
 	"github.com/downflux/go-bvh/internal/cache"
+	"github.com/downflux/go-bvh/internal/cache/node"
+	"github.com/downflux/go-bvh/internal/cache/node/util"
 	"github.com/downflux/go-bvh/internal/cache/node/util/metrics"
 	"github.com/downflux/go-geometry/nd/hyperrectangle"
 	"github.com/downflux/go-geometry/nd/ray"
@@ -18,16 +27,61 @@ import (
 )
 
 type T struct {
+	// o tracks the input options used to generate the tree, and is used
+	// when serializing the tree.
+	o O
+
 	c    *cache.C
 	root cid.ID
 
 	nodes map[id.ID]cid.ID
 	data  map[id.ID]hyperrectangle.R
 
+	// bounds tracks the AABBs used to calculate the leaf node AABBs, and
+	// is passed to the insert and remove ops in place of the object data.
+	// The bounds of an object must contain the object AABB, and usually
+	// shares the same underlying buffer as the object AABB. The bounds may
+	// differ from the object AABB if the object was moved with a
+	// displacement, in which case the bounds track the swept AABB.
+	bounds map[id.ID]hyperrectangle.R
+
+	// objects tracks the per-object options of objects which were inserted
+	// with non-default options.
+	objects map[id.ID]InsertO
+
+	// static is the set of objects which were inserted as static, and is
+	// passed to node.SetAABB, which does not expand leaf nodes that only
+	// contain static objects.
+	static map[id.ID]struct{}
+
 	tolerance float64
 
+	// baseline is the tree cost at the start of the current sequence of
+	// Refit calls, and is only valid if hasBaseline is set. hasBaseline is
+	// reset whenever the tree topology changes.
+	baseline    float64
+	hasBaseline bool
+
 	insert insert.O
 	remove remove.O
+
+	// scratch is a pool of buffers used by the callback-style queries,
+	// which allows these queries to avoid heap allocations. Each query in
+	// flight uses the buffer at its own nesting depth, so that the query
+	// callback may itself query the tree.
+	scratch []*scratch
+	depth   int
+}
+
+// scratch holds the buffers of a single callback-style query.
+type scratch struct {
+	// open is the traversal stack.
+	open []node.N
+
+	// ids and collect are used to sort results in deterministic mode. The
+	// collect function appends to ids, and is allocated once per buffer.
+	ids     []id.ID
+	collect func(x id.ID) bool
 }
 
 type O struct {
@@ -38,28 +92,339 @@ type O struct {
 	// percentage of the volume of the AABB. This value must be greater than
 	// one (as the resultant AABB must encapsulate the leaf).
 	Tolerance float64
+
+	// Margin specifies a fixed absolute padding which is added to each
+	// side of an object AABB along every axis when calculating the leaf
+	// AABBs. Unlike Tolerance, the margin also buffers zero-width or thin
+	// objects (e.g. walls, line segments, and points).
+	//
+	// The margin is applied before the Tolerance factor; set Tolerance to
+	// 1 for a purely absolute margin, or to a larger value for a combined
+	// absolute and relative margin.
+	Margin float64
+
+	// Build specifies the construction strategy used by NewFromData. This
+	// option does not affect trees created via New.
+	Build Build
+
+	// Candidate, Split, and Balance specify the strategies used to insert
+	// and remove objects from the tree. The zero values of these options
+	// correspond to the library defaults, which are tuned for frequently
+	// updated trees.
+	Candidate Candidate
+	Split     Split
+	Balance   Balance
+
+	// Heuristic specifies the node cost function used by the insert,
+	// remove, and build strategies.
+	Heuristic Heuristic
+
+	// Deterministic specifies that query results, including the results of
+	// snapshot queries, are returned in a fixed order, i.e. sorted by
+	// object ID. Together with the deterministic tree layout, this ensures
+	// identical outputs (e.g. across clients in a lockstep simulation)
+	// given the same sequence of tree operations.
+	//
+	// N.B.: The tree layout is always deterministic, and the sorted
+	// queries (e.g. KNN, RaycastSorted) always break ties by object ID,
+	// regardless of this option.
+	Deterministic bool
+}
+
+// InsertO specifies per-object options which override the tree defaults. The
+// zero value corresponds to the tree defaults.
+type InsertO struct {
+	// Margin overrides the tree absolute margin for this object, e.g. to
+	// give a fast-moving projectile a generous padding, or a zero margin
+	// to an object in a tree with a non-zero margin. If nil, the tree
+	// margin is used.
+	Margin *float64
+
+	// Static marks the object as not expected to move, e.g. a building.
+	// Static objects are not padded by the margin, and leaf nodes which
+	// only contain static objects are not expanded by the tree tolerance,
+	// which keeps the leaf AABBs tight.
+	//
+	// Static objects may still be updated, though doing so will usually
+	// force a reinsertion.
+	Static bool
+}
+
+func (o InsertO) validate() error {
+	if o.Margin != nil && (!(*o.Margin >= 0) || math.IsInf(*o.Margin, 0)) {
+		return fmt.Errorf("invalid margin %v", *o.Margin)
+	}
+	return nil
+}
+
+func (o O) validate() error {
+	if o.K <= 0 {
+		return fmt.Errorf("invalid AABB dimension %v", o.K)
+	}
+	if o.LeafSize <= 0 {
+		return fmt.Errorf("invalid leaf size %v", o.LeafSize)
+	}
+	if !(o.Tolerance >= 1) {
+		return fmt.Errorf("cannot set tolerance factor %v < 1", o.Tolerance)
+	}
+	if !(o.Margin >= 0) || math.IsInf(o.Margin, 0) {
+		return fmt.Errorf("invalid margin %v", o.Margin)
+	}
+	if o.Build < BuildSAH || o.Build > BuildMortonRestructured {
+		return fmt.Errorf("invalid build strategy %v", o.Build)
+	}
+	if o.Candidate < CandidateGuttman || o.Candidate > CandidateRStar {
+		return fmt.Errorf("invalid candidate strategy %v", o.Candidate)
+	}
+	if o.Split < SplitGuttmanLinear || o.Split > SplitDHConnelly {
+		return fmt.Errorf("invalid split strategy %v", o.Split)
+	}
+	if o.Balance < BalanceBrianNoyamaNoDF || o.Balance > BalanceTreelet {
+		return fmt.Errorf("invalid balance strategy %v", o.Balance)
+	}
+	if o.Heuristic < HeuristicSA || o.Heuristic > HeuristicBrianNoyama {
+		return fmt.Errorf("invalid heuristic %v", o.Heuristic)
+	}
+	return nil
 }
 
 func New(o O) *T {
-	if o.Tolerance < 1 {
-		panic(fmt.Sprintf("cannot set tolerance factor %v < 1", o.Tolerance))
+	if err := o.validate(); err != nil {
+		panic(err.Error())
 	}
 
 	return &T{
+		o: o,
+
 		c: cache.New(cache.O{
-			K:        o.K,
-			LeafSize: o.LeafSize,
+			K:         o.K,
+			LeafSize:  o.LeafSize,
+			Heuristic: o.Heuristic.heuristic(),
 		}),
 
 		root: cid.IDInvalid,
 
 		nodes:     make(map[id.ID]cid.ID, 1024),
 		data:      make(map[id.ID]hyperrectangle.R, 1024),
+		bounds:    make(map[id.ID]hyperrectangle.R, 1024),
+		objects:   make(map[id.ID]InsertO),
+		static:    make(map[id.ID]struct{}),
 		tolerance: o.Tolerance,
 
-		insert: insert.Default,
-		remove: remove.Default,
+		insert: insert.O{
+			Candidate: o.Candidate.candidate(),
+			Split:     o.Split.split(),
+			Balance:   o.Balance.balance(),
+		},
+		remove: remove.O{
+			Balance: o.Balance.balance(),
+		},
+	}
+}
+
+// sort returns the input query results in deterministic order if the tree is
+// in deterministic mode.
+func (t *T) sort(ids []id.ID) []id.ID {
+	if t.o.Deterministic {
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	}
+	return ids
+}
+
+// sortP returns the input pairs in deterministic order if sorted is set.
+func sortP(ps []query.P, sorted bool) []query.P {
+	if sorted {
+		sort.Slice(ps, func(i, j int) bool {
+			return ps[i].A < ps[j].A || (ps[i].A == ps[j].A && ps[i].B < ps[j].B)
+		})
+	}
+	return ps
+}
+
+// acquire returns the buffers for a new callback-style query. The buffers must
+// be returned via release once the query finishes.
+func (t *T) acquire() *scratch {
+	if t.depth == len(t.scratch) {
+		s := &scratch{
+			open: make([]node.N, 0, 128),
+		}
+		if t.o.Deterministic {
+			s.ids = make([]id.ID, 0, 128)
+			s.collect = func(x id.ID) bool {
+				s.ids = append(s.ids, x)
+				return true
+			}
+		}
+		t.scratch = append(t.scratch, s)
+	}
+
+	s := t.scratch[t.depth]
+	t.depth++
+	return s
+}
+
+func (t *T) release() { t.depth-- }
+
+// f returns the callback to be passed to the callback-style queries. In
+// deterministic mode, results are buffered and only passed to the user
+// callback in flush.
+func (s *scratch) f(f func(x id.ID) bool) func(x id.ID) bool {
+	if s.collect != nil {
+		s.ids = s.ids[:0]
+		return s.collect
+	}
+	return f
+}
+
+// flush passes the buffered query results to the user callback in
+// deterministic mode.
+func (s *scratch) flush(f func(x id.ID) bool) {
+	if s.collect == nil {
+		return
+	}
+	sort.Slice(s.ids, func(i, j int) bool { return s.ids[i] < s.ids[j] })
+	for _, x := range s.ids {
+		if !f(x) {
+			break
+		}
+	}
+	s.ids = s.ids[:0]
+}
+
+// NewFromData constructs a tree which contains all objects in the input data
+// set. This is significantly faster than inserting each object one at a time,
+// and will generally produce a tree with a lower SAH. The construction
+// algorithm is specified by o.Build.
+//
+// The returned tree supports all the usual Insert, Update, and Remove calls.
+// As with Insert, the input AABBs are copied.
+func NewFromData(o O, data map[id.ID]hyperrectangle.R) *T {
+	t := New(o)
+
+	for x, aabb := range data {
+		buf := hyperrectangle.New(
+			vector.V(make([]float64, aabb.Min().Dimension())),
+			vector.V(make([]float64, aabb.Min().Dimension())),
+		).M()
+		buf.Copy(aabb)
+
+		t.data[x] = buf.R()
+		t.setBounds(x, nil)
+	}
+
+	root := o.Build.builder()(t.c, t.bounds, t.static, t.tolerance)
+	if root == nil {
+		return t
 	}
+
+	t.root = root.ID()
+	util.PreOrder(root, func(n node.N) {
+		for x := range n.Leaves() {
+			t.nodes[x] = n.ID()
+		}
+	})
+
+	return t
+}
+
+// Clone returns a deep copy of the tree. The returned tree has an identical
+// structure and internal node IDs, and may be mutated independently of the
+// source tree.
+func (t *T) Clone() *T {
+	u := New(t.o)
+
+	u.c = t.c.Clone()
+	u.root = t.root
+	u.baseline = t.baseline
+	u.hasBaseline = t.hasBaseline
+	for x, n := range t.nodes {
+		u.nodes[x] = n
+	}
+	for x, o := range t.objects {
+		u.objects[x] = o
+	}
+	for x := range t.static {
+		u.static[x] = struct{}{}
+	}
+	for x, aabb := range t.data {
+		buf := hyperrectangle.New(
+			vector.V(make([]float64, aabb.Min().Dimension())),
+			vector.V(make([]float64, aabb.Min().Dimension())),
+		).M()
+		buf.Copy(aabb)
+
+		u.data[x] = buf.R()
+		u.bounds[x] = u.data[x]
+		if t.hasBounds(x) {
+			buf := hyperrectangle.New(
+				vector.V(make([]float64, aabb.Min().Dimension())),
+				vector.V(make([]float64, aabb.Min().Dimension())),
+			).M()
+			buf.Copy(t.bounds[x])
+
+			u.bounds[x] = buf.R()
+		}
+	}
+
+	return u
+}
+
+// hasBounds checks if the leaf bounds of the input object differ from the
+// object AABB, e.g. if the object was padded by the tree margin or moved with a
+// non-zero displacement.
+func (t *T) hasBounds(x id.ID) bool {
+	r, s := t.data[x], t.bounds[x]
+	for i := vector.D(0); i < t.o.K; i++ {
+		if r.Min()[i] != s.Min()[i] || r.Max()[i] != s.Max()[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// Budget limits the amount of work done by a single Optimize call.
+type Budget struct {
+	// Nodes is the maximum number of internal nodes to reinsert. If zero,
+	// the number of nodes is not limited.
+	Nodes int
+
+	// Duration is the maximum amount of time to spend in the call. If
+	// zero, the time is not limited.
+	//
+	// N.B.: A time budget will result in a nondeterministic tree layout.
+	Duration time.Duration
+}
+
+// Optimize incrementally lowers the cost of the tree via the insertion-based
+// optimization in Bittner et al. 2013, i.e. by removing the internal nodes
+// with the highest inefficiency and reinserting their children at the
+// positions which minimize the total tree cost.
+//
+// The input budget limits the amount of work done, which allows the caller to
+// amortize the optimization over several calls, e.g. once per idle frame. With
+// an empty budget, every internal node is visited once. Optimize returns the
+// number of reinserted nodes.
+func (t *T) Optimize(b Budget) int {
+	start := time.Now()
+
+	var n int
+	root, k := optimize.Optimize(t.c, t.root, func() bool {
+		if b.Nodes > 0 && n >= b.Nodes {
+			return false
+		}
+		if b.Duration > 0 && time.Since(start) >= b.Duration {
+			return false
+		}
+		n++
+		return true
+	})
+	if root != nil {
+		t.root = root.ID()
+	}
+	if k > 0 {
+		t.hasBaseline = false
+	}
+	return k
 }
 
 // SAH returns the surface area heuristic as defined by MacDonald and Booth
@@ -73,12 +438,58 @@ func (t *T) SAH() float64 {
 	return metrics.SAH(n)
 }
 
+// Cursor returns a read-only cursor pointing at the root of the tree. If the
+// tree is empty, the returned cursor is invalid.
+//
+// The cursor is only valid until the next mutation of the tree.
+func (t *T) Cursor() cursor.C {
+	n, ok := t.c.Get(t.root)
+	if !ok {
+		return cursor.C{}
+	}
+	return cursor.New(n)
+}
+
+// Snapshot returns an immutable, query-only copy of the tree. The snapshot
+// flattens the tree into contiguous arrays, and is therefore cheaper to
+// generate and query than a full copy of the tree.
+//
+// The snapshot does not share memory with the tree, and may be safely queried
+// concurrently while the tree is mutated.
+func (t *T) Snapshot() *snapshot.S {
+	return snapshot.New(t.c, t.root, t.data, t.o.Deterministic)
+}
+
+// DOT writes the tree as a Graphviz DOT graph, which may be rendered with e.g.
+//
+//	dot -Tsvg tree.dot > tree.svg
+//
+// Each node is labeled with its node ID, height, and heuristic, and leaf nodes
+// also list the objects they contain.
+func (t *T) DOT(w io.Writer) error {
+	n, ok := t.c.Get(t.root)
+	if !ok {
+		return util.DOT(w, nil)
+	}
+	return util.DOT(w, n)
+}
+
+// SVG draws the fat AABBs of all tree nodes, colored by depth, with the object
+// AABBs overlaid. This is only supported for trees with K = 2.
+func (t *T) SVG(w io.Writer) error {
+	n, ok := t.c.Get(t.root)
+	if !ok {
+		return util.SVG(w, nil, t.data)
+	}
+	return util.SVG(w, n, t.data)
+}
+
 func (t *T) IDs() []id.ID {
 	ids := make([]id.ID, 0, len(t.data))
 	for x := range t.data {
 		ids = append(ids, x)
 	}
-	return ids
+	return t.sort(ids)
 }
 
 // Insert adds a new AABB into the BVH. The specific data structure which tracks
@@ -89,29 +500,122 @@ func (t *T) IDs() []id.ID {
 // Because the AABB must remain static inside the BVH, we will create a new copy
 // of the input.
 func (t *T) Insert(x id.ID, aabb hyperrectangle.R) error {
+	return t.InsertWithOptions(x, aabb, InsertO{})
+}
+
+// InsertWithOptions adds a new AABB into the BVH as with Insert, but allows
+// the caller to override the tree defaults for this object. The options are
+// kept for the lifetime of the object, i.e. across calls to Update and Move.
+func (t *T) InsertWithOptions(x id.ID, aabb hyperrectangle.R, o InsertO) error {
 	if _, ok := t.data[x]; ok {
 		return fmt.Errorf("cannot insert a duplicate node %v", x)
 	}
+	if err := o.validate(); err != nil {
+		return fmt.Errorf("cannot insert node %v: %v", x, err)
+	}
+
+	// Copy the margin, as the caller may reuse the underlying variable.
+	if o.Margin != nil {
+		m := *o.Margin
+		o.Margin = &m
+	}
+	if o != (InsertO{}) {
+		t.objects[x] = o
+	}
+	if o.Static {
+		t.static[x] = struct{}{}
+	}
 
-	buf := hyperrectangle.New(
-		vector.V(make([]float64, aabb.Min().Dimension())),
-		vector.V(make([]float64, aabb.Min().Dimension())),
-	).M()
-	buf.Copy(aabb)
+	t.add(x, aabb, nil)
+	return nil
+}
 
-	t.data[x] = buf.R()
+// add inserts an object into the tree. If the displacement d is non-nil, the
+// leaf AABB will cover the object AABB swept along d.
+func (t *T) add(x id.ID, aabb hyperrectangle.R, d vector.V) {
+	t.set(x, aabb, d)
 
 	root, mutations := t.insert.Insert(
-		t.c, t.root, t.data, x, t.tolerance,
+		t.c, t.root, t.bounds, t.static, x, t.tolerance,
 	)
 	t.root = root.ID()
+	t.hasBaseline = false
 	for _, n := range mutations {
 		for x := range n.Leaves() {
 			t.nodes[x] = n.ID()
 		}
 	}
+}
 
-	return nil
+// set copies the input AABB into the object buffer, and updates the object leaf
+// bounds. The existing object buffer is reused if the object is already
+// tracked by the tree.
+func (t *T) set(x id.ID, aabb hyperrectangle.R, d vector.V) {
+	if buf, ok := t.data[x]; ok {
+		buf.M().Copy(aabb)
+	} else {
+		buf := hyperrectangle.New(
+			vector.V(make([]float64, aabb.Min().Dimension())),
+			vector.V(make([]float64, aabb.Min().Dimension())),
+		).M()
+		buf.Copy(aabb)
+
+		t.data[x] = buf.R()
+	}
+	t.setBounds(x, d)
+}
+
+// margin returns the absolute margin of the input object.
+func (t *T) margin(x id.ID) float64 {
+	o := t.objects[x]
+	switch {
+	case o.Static:
+		return 0
+	case o.Margin != nil:
+		return *o.Margin
+	default:
+		return t.o.Margin
+	}
+}
+
+// setBounds updates the leaf bounds of the input object, i.e. the object AABB
+// padded by the object margin, and swept along the displacement d if d is
+// non-nil.
+//
+// If no padding is necessary, the leaf bounds will share the same underlying
+// buffer as the object AABB.
+func (t *T) setBounds(x id.ID, d vector.V) {
+	aabb := t.data[x]
+	margin := t.margin(x)
+	if margin == 0 && d == nil {
+		t.bounds[x] = aabb
+		return
+	}
+
+	// Reuse the existing bounds buffer, as long as the buffer is not
+	// shared with the object AABB.
+	b, ok := t.bounds[x]
+	if !ok || &b.Min()[0] == &aabb.Min()[0] {
+		b = *hyperrectangle.New(
+			vector.V(make([]float64, t.o.K)),
+			vector.V(make([]float64, t.o.K)),
+		)
+		t.bounds[x] = b
+	}
+
+	bmin, bmax := b.Min(), b.Max()
+	for i := vector.D(0); i < t.o.K; i++ {
+		bmin[i] = aabb.Min()[i] - margin
+		bmax[i] = aabb.Max()[i] + margin
+		if d == nil {
+			continue
+		}
+		if d[i] < 0 {
+			bmin[i] += d[i]
+		} else {
+			bmax[i] += d[i]
+		}
+	}
 }
 
 // Update will move a corresponding object. Depending on the BVH tolerance and
@@ -124,50 +628,218 @@ func (t *T) Update(x id.ID, aabb hyperrectangle.R) error {
 
 	n := t.c.GetOrDie(t.nodes[x])
 	if !hyperrectangle.Contains(n.AABB().R(), aabb) {
-		if err := t.Remove(x); err != nil {
-			return fmt.Errorf("cannot update node %v: %v", x, err)
-		}
-		if err := t.Insert(x, aabb); err != nil {
-			return fmt.Errorf("cannot update node %v: %v", x, err)
-		}
+		t.unlink(x)
+		t.add(x, aabb, nil)
 	} else {
 		// As with the Insert call, we do not have any guarantees the
 		// AABB will not be mutated after the update call, so we must
 		// ensure this is true by making a copy of the input.
 		t.data[x].M().Copy(aabb)
+		t.setBounds(x, nil)
+	}
+
+	return nil
+}
+
+// Move updates the AABB of an object as with Update, but additionally accepts
+// the expected displacement d of the object before its next update, e.g. the
+// object velocity multiplied by the tick duration.
+//
+// If the object needs to be reinserted into the tree, the new leaf AABB will
+// cover the object AABB swept along d (in addition to the usual tolerance
+// buffer). This is the predictive AABB enlargement used by Box2D, and greatly
+// reduces the reinsertion churn of fast, predictably moving objects.
+func (t *T) Move(x id.ID, aabb hyperrectangle.R, d vector.V) error {
+	if _, ok := t.data[x]; !ok {
+		return fmt.Errorf("cannot move a non-existent node %v", x)
+	}
+	if d.Dimension() != t.o.K {
+		return fmt.Errorf("cannot move node %v with a displacement of dimension %v", x, d.Dimension())
+	}
+
+	n := t.c.GetOrDie(t.nodes[x])
+	if hyperrectangle.Contains(n.AABB().R(), aabb) {
+		t.data[x].M().Copy(aabb)
+		t.setBounds(x, nil)
+		return nil
 	}
 
+	t.unlink(x)
+	t.add(x, aabb, d)
+
 	return nil
 }
 
+// Refit updates the AABBs of several objects at once without changing the tree
+// topology. Each affected node AABB is recalculated exactly once, bottom-up from
+// the leaves. Unlike Update, objects are never reinserted, which makes Refit
+// suitable for deforming scenes (e.g. skeletal or cloth meshes), where objects
+// move slightly every frame, but the hierarchy remains a good fit.
+//
+// As the tree topology is not updated, the tree cost may degrade over time;
+// see Degradation.
+//
+// If any of the input objects do not exist in the tree, or have an invalid
+// dimension, Refit returns an error and does not modify the tree.
+func (t *T) Refit(updates map[id.ID]hyperrectangle.R) error {
+	for x, aabb := range updates {
+		if _, ok := t.data[x]; !ok {
+			return fmt.Errorf("cannot refit a non-existent node %v", x)
+		}
+		if d := aabb.Min().Dimension(); d != t.o.K {
+			return fmt.Errorf("cannot refit node %v with an AABB of dimension %v", x, d)
+		}
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+
+	if !t.hasBaseline {
+		t.baseline = t.SAH()
+		t.hasBaseline = true
+	}
+
+	// dirty tracks the set of nodes whose AABBs need to be updated.
+	dirty := make(map[cid.ID]node.N, len(updates))
+	for x, aabb := range updates {
+		t.data[x].M().Copy(aabb)
+		t.setBounds(x, nil)
+
+		for n := t.c.GetOrDie(t.nodes[x]); n != nil; n = n.Parent() {
+			if _, ok := dirty[n.ID()]; ok {
+				break
+			}
+			dirty[n.ID()] = n
+		}
+	}
+
+	// As the tree topology is unchanged, the cached node heights are
+	// valid, and ensure the children of a node are updated before the
+	// node itself.
+	ns := make([]node.N, 0, len(dirty))
+	for _, n := range dirty {
+		ns = append(ns, n)
+	}
+	sort.Slice(ns, func(i, j int) bool {
+		if ns[i].Height() != ns[j].Height() {
+			return ns[i].Height() < ns[j].Height()
+		}
+		return ns[i].ID() < ns[j].ID()
+	})
+	for _, n := range ns {
+		node.SetAABB(n, t.bounds, t.static, t.tolerance)
+	}
+
+	return nil
+}
+
+// Degradation returns the ratio of the current tree cost to the tree cost at
+// the start of the current sequence of Refit calls. Any operation which changes
+// the tree topology (e.g. Insert, Remove, Optimize, or an Update which
+// reinserts an object) starts a new sequence.
+//
+// A ratio significantly larger than one (e.g. 1.5) indicates the hierarchy no
+// longer fits the scene, and that the tree should be rebuilt, e.g. via
+// Optimize or NewFromData.
+//
+// If the baseline tree cost is zero (e.g. for a tree of points with no margin),
+// any subsequent increase in the tree cost is reported as an infinite ratio.
+func (t *T) Degradation() float64 {
+	if !t.hasBaseline {
+		return 1
+	}
+	h := t.SAH()
+	if t.baseline == 0 {
+		if h == 0 {
+			return 1
+		}
+		return math.Inf(1)
+	}
+	return h / t.baseline
+}
+
 func (t *T) Remove(x id.ID) error {
 	if _, ok := t.data[x]; !ok {
 		return fmt.Errorf("cannot remove a non-existent node %v", x)
 	}
 
+	t.unlink(x)
+
+	delete(t.data, x)
+	delete(t.bounds, x)
+	delete(t.objects, x)
+	delete(t.static, x)
+
+	return nil
+}
+
+// unlink removes the input object from the tree nodes, but preserves the
+// object data and options, e.g. for a subsequent reinsertion.
+func (t *T) unlink(x id.ID) {
 	root := t.remove.Remove(
-		t.c, t.data, t.nodes[x], x, t.tolerance,
+		t.c, t.bounds, t.static, t.nodes[x], x, t.tolerance,
 	)
 	if root != nil {
 		t.root = root.ID()
 	} else {
 		t.root = cid.IDInvalid
 	}
+	t.hasBaseline = false
 
 	delete(t.nodes, x)
-	delete(t.data, x)
-
-	return nil
 }
 
 // BroadPhase finds all objects which intersect with the given input AABB.
 func (t *T) BroadPhase(q hyperrectangle.R) []id.ID {
-	return query.BroadPhase(t.c, t.root, t.data, q)
+	return t.sort(query.BroadPhase(t.c, t.root, t.data, q))
 }
 
 // Raycast finds all objcets which intersects the given ray.
 func (t *T) Raycast(q ray.R) []id.ID {
-	return query.Raycast(t.c, t.root, t.data, q)
+	return t.sort(query.Raycast(t.c, t.root, t.data, q))
+}
+
+// Pairs finds all unordered pairs of objects in the tree which overlap one
+// another. Each pair is reported exactly once.
+func (t *T) Pairs() []query.P {
+	return sortP(query.Pairs(t.c, t.root, t.data), t.o.Deterministic)
+}
+
+// CrossPairs finds all pairs of objects (x, y) which overlap one another, where
+// x is an object in s and y is an object in t. Both trees must have the same
+// dimension K.
+//
+// This is useful for e.g. checking a set of dynamic objects against a separate
+// tree of static objects, where the two trees may have different tolerances.
+func CrossPairs(s *T, t *T) []query.P {
+	if s.c.K() != t.c.K() {
+		panic(fmt.Sprintf("cannot compare trees of mismatching dimensions %v and %v", s.c.K(), t.c.K()))
+	}
+	return sortP(
+		query.CrossPairs(s.c, s.root, s.data, t.c, t.root, t.data),
+		s.o.Deterministic || t.o.Deterministic,
+	)
+}
+
+// KNN finds the k objects closest to the input point p, sorted by increasing
+// distance. The distance to an object is measured to the closest point of its
+// AABB, so any object which contains p has a distance of zero.
+func (t *T) KNN(p vector.V, k int) []id.ID {
+	return query.KNN(t.c, t.root, t.data, p, k)
+}
+
+// RaycastFirst finds the object which the ray intersects first, i.e. the object
+// with the smallest entry distance along the ray. Only hits with an entry
+// distance of at most max are considered; set max to math.Inf(1) for an
+// unbounded ray.
+func (t *T) RaycastFirst(q ray.R, max float64) (query.Hit, bool) {
+	return query.RaycastFirst(t.c, t.root, t.data, q, max)
+}
+
+// RaycastSorted finds all objects which intersect the ray within the distance
+// max, sorted front-to-back by their entry distance.
+func (t *T) RaycastSorted(q ray.R, max float64) []query.Hit {
+	return query.RaycastSorted(t.c, t.root, t.data, q, max)
 }
 
 // Query finds all objects which passes the input filtering function. BroadPhase
@@ -175,5 +847,44 @@ func (t *T) Raycast(q ray.R) []id.ID {
 // recursively applied; that is, the child of an internal BVH node will be
 // searched only if the parent AABB also passes the filter.
 func (t *T) Query(f func(r hyperrectangle.R) bool) []id.ID {
-	return query.Query(t.c, t.root, t.data, f)
+	return t.sort(query.Query(t.c, t.root, t.data, f))
+}
+
+// BroadPhaseFunc calls f on each object which intersects the input AABB. The
+// search stops as soon as f returns false.
+//
+// Unlike BroadPhase, this function does not allocate a result slice, and reuses
+// an internal traversal buffer across calls. The function f must not mutate
+// the tree, but may itself query the tree, e.g. to run a broad-phase query for
+// each hit.
+//
+// In deterministic mode, all results are found (and sorted) before f is called,
+// and so returning false from f will not shorten the search.
+func (t *T) BroadPhaseFunc(q hyperrectangle.R, f func(x id.ID) bool) {
+	s := t.acquire()
+	defer t.release()
+
+	s.open = query.BroadPhaseFunc(t.c, t.root, t.data, s.open, q, s.f(f))
+	s.flush(f)
+}
+
+// RaycastFunc calls f on each object which intersects the given ray. The search
+// stops as soon as f returns false. See BroadPhaseFunc for more details.
+func (t *T) RaycastFunc(q ray.R, f func(x id.ID) bool) {
+	s := t.acquire()
+	defer t.release()
+
+	s.open = query.RaycastFunc(t.c, t.root, t.data, s.open, q, s.f(f))
+	s.flush(f)
+}
+
+// QueryFunc calls f on each object which passes the input filter. The search
+// stops as soon as f returns false. See Query and BroadPhaseFunc for more
+// details.
+func (t *T) QueryFunc(q func(r hyperrectangle.R) bool, f func(x id.ID) bool) {
+	s := t.acquire()
+	defer t.release()
+
+	s.open = query.QueryFunc(t.c, t.root, t.data, s.open, q, s.f(f))
+	s.flush(f)
 }
@@ -1,18 +1,29 @@
 package bvh
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"math"
+	"math/rand"
+	"sort"
 	"testing"
 
+	"github.com/downflux/go-bvh/bvh/cursor"
+	"github.com/downflux/go-bvh/bvh/op/query"
 	"github.com/downflux/go-bvh/container/bruteforce"
 	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-bvh/internal/cache/node"
+	"github.com/downflux/go-bvh/internal/cache/node/util"
 	"github.com/downflux/go-bvh/perf"
 	"github.com/downflux/go-geometry/nd/hyperrectangle"
+	"github.com/downflux/go-geometry/nd/ray"
 	"github.com/downflux/go-geometry/nd/vector"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 
 	cid "github.com/downflux/go-bvh/internal/cache/id"
+	ncmp "github.com/downflux/go-bvh/internal/cache/node/util/cmp"
 )
 
 func TestDelete(t *testing.T) {
@@ -147,3 +158,1876 @@ func TestBroadPhaseConformance(t *testing.T) {
 		})
 	}
 }
+
+func TestKNNConformance(t *testing.T) {
+	const k = 3
+	type config struct {
+		size int
+		data map[id.ID]hyperrectangle.R
+		p    vector.V
+		n    int
+	}
+
+	configs := []config{
+		{
+			size: 4,
+			data: map[id.ID]hyperrectangle.R{},
+			p:    vector.V{0, 0, 0},
+			n:    8,
+		},
+		{
+			size: 1,
+			data: map[id.ID]hyperrectangle.R{
+				100: *hyperrectangle.New(
+					vector.V([]float64{0, 0, 0}),
+					vector.V([]float64{1, 1, 1}),
+				),
+				101: *hyperrectangle.New(
+					vector.V([]float64{10, 10, 10}),
+					vector.V([]float64{11, 11, 11}),
+				),
+			},
+			p: vector.V{9, 9, 9},
+			n: 8,
+		},
+		{
+			size: 1,
+			data: perf.GenerateRandomBoxes(1000, k, 100, 200),
+			p:    vector.V{50, 50, 50},
+			n:    8,
+		},
+		{
+			size: 4,
+			data: perf.GenerateRandomBoxes(1000, k, 100, 200),
+			p:    vector.V{0, 0, 0},
+			n:    50,
+		},
+	}
+
+	for _, c := range configs {
+		t.Run(fmt.Sprintf("BVH/K=%v/LeafSize=%v/N=%v/k=%v", k, c.size, len(c.data), c.n), func(t *testing.T) {
+			tbvh := New(O{
+				K:         k,
+				LeafSize:  c.size,
+				Tolerance: 1.05,
+			})
+
+			var want []float64
+			for x, h := range c.data {
+				tbvh.Insert(x, h)
+				want = append(want, query.D(c.p, h))
+			}
+			sort.Float64s(want)
+			if len(want) > c.n {
+				want = want[:c.n]
+			}
+
+			// Ties between objects may be broken arbitrarily, so we
+			// compare the distances of the results instead.
+			got := []float64{}
+			for _, x := range tbvh.KNN(c.p, c.n) {
+				got = append(got, query.D(c.p, c.data[x]))
+			}
+			if want == nil {
+				want = []float64{}
+			}
+
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("KNN() mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestRaycastSortedConformance(t *testing.T) {
+	const k = 3
+	type config struct {
+		size int
+		data map[id.ID]hyperrectangle.R
+		q    ray.R
+		max  float64
+	}
+
+	configs := []config{
+		{
+			size: 4,
+			data: map[id.ID]hyperrectangle.R{},
+			q:    *ray.New(vector.V{0, 0, 0}, vector.V{1, 1, 1}),
+			max:  math.Inf(1),
+		},
+		{
+			size: 1,
+			data: perf.GenerateRandomBoxes(1000, k, 100, 200),
+			q:    *ray.New(vector.V{0, 0, 0}, vector.V{1, 1, 1}),
+			max:  math.Inf(1),
+		},
+		{
+			size: 4,
+			data: perf.GenerateRandomBoxes(1000, k, 100, 200),
+			q:    *ray.New(vector.V{0, 0, 0}, vector.V{1, 1, 1}),
+			max:  math.Inf(1),
+		},
+		{
+			size: 4,
+			data: perf.GenerateRandomBoxes(1000, k, 100, 200),
+			q:    *ray.New(vector.V{0, 0, 0}, vector.V{1, 1, 1}),
+			max:  50,
+		},
+	}
+
+	for _, c := range configs {
+		t.Run(fmt.Sprintf("BVH/K=%v/LeafSize=%v/N=%v/Max=%v", k, c.size, len(c.data), c.max), func(t *testing.T) {
+			tbvh := New(O{
+				K:         k,
+				LeafSize:  c.size,
+				Tolerance: 1.05,
+			})
+
+			want := []float64{}
+			for x, h := range c.data {
+				tbvh.Insert(x, h)
+				if d, ok := query.IntersectT(c.q, h); ok && d <= c.max {
+					want = append(want, d)
+				}
+			}
+			sort.Float64s(want)
+
+			got := []float64{}
+			for _, h := range tbvh.RaycastSorted(c.q, c.max) {
+				got = append(got, h.T)
+			}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("RaycastSorted() mismatch (-want +got):\n%v", diff)
+			}
+
+			h, ok := tbvh.RaycastFirst(c.q, c.max)
+			if ok != (len(want) > 0) {
+				t.Fatalf("RaycastFirst() = _, %v, want = _, %v", ok, len(want) > 0)
+			}
+			if ok && h.T != want[0] {
+				t.Errorf("RaycastFirst() = %v, _, want = %v, _", h.T, want[0])
+			}
+		})
+	}
+}
+
+func TestBroadPhaseFunc(t *testing.T) {
+	const k = 3
+
+	data := perf.GenerateRandomBoxes(1000, k, 100, 200)
+	q := perf.GenerateAABB(k, 50, 70)
+
+	tbvh := New(O{
+		K:         k,
+		LeafSize:  4,
+		Tolerance: 1.05,
+	})
+	for x, h := range data {
+		tbvh.Insert(x, h)
+	}
+
+	t.Run("Conformance", func(t *testing.T) {
+		want := tbvh.BroadPhase(q)
+		got := []id.ID{}
+		tbvh.BroadPhaseFunc(q, func(x id.ID) bool {
+			got = append(got, x)
+			return true
+		})
+
+		if diff := cmp.Diff(
+			want, got,
+			cmpopts.SortSlices(func(a, b id.ID) bool { return a < b }),
+		); diff != "" {
+			t.Errorf("BroadPhaseFunc() mismatch (-want +got):\n%v", diff)
+		}
+	})
+
+	t.Run("EarlyTermination", func(t *testing.T) {
+		if len(tbvh.BroadPhase(q)) < 2 {
+			t.Skip("query does not return enough objects")
+		}
+
+		var n int
+		tbvh.BroadPhaseFunc(q, func(x id.ID) bool {
+			n++
+			return false
+		})
+		if n != 1 {
+			t.Errorf("BroadPhaseFunc() visited %v objects, want = %v", n, 1)
+		}
+	})
+
+	t.Run("Nested", func(t *testing.T) {
+		want := map[id.ID][]id.ID{}
+		for _, x := range tbvh.BroadPhase(q) {
+			want[x] = tbvh.BroadPhase(data[x])
+		}
+
+		got := map[id.ID][]id.ID{}
+		tbvh.BroadPhaseFunc(q, func(x id.ID) bool {
+			got[x] = []id.ID{}
+			tbvh.BroadPhaseFunc(data[x], func(y id.ID) bool {
+				got[x] = append(got[x], y)
+				return true
+			})
+			return true
+		})
+
+		if diff := cmp.Diff(
+			want, got,
+			cmpopts.SortSlices(func(a, b id.ID) bool { return a < b }),
+		); diff != "" {
+			t.Errorf("BroadPhaseFunc() mismatch (-want +got):\n%v", diff)
+		}
+	})
+
+	t.Run("Allocs", func(t *testing.T) {
+		var n int
+		f := func(x id.ID) bool {
+			n++
+			return true
+		}
+
+		// Warm up the internal traversal buffer.
+		tbvh.BroadPhaseFunc(q, f)
+		if got := testing.AllocsPerRun(100, func() { tbvh.BroadPhaseFunc(q, f) }); got != 0 {
+			t.Errorf("BroadPhaseFunc() allocated %v times per run, want = %v", got, 0)
+		}
+	})
+}
+
+func TestRaycastFuncConformance(t *testing.T) {
+	const k = 3
+
+	configs := []struct {
+		name string
+		data map[id.ID]hyperrectangle.R
+		q    ray.R
+	}{
+		{
+			name: "Random",
+			data: perf.GenerateRandomBoxes(1000, k, 100, 200),
+			q:    *ray.New(vector.V{0, 0, 0}, vector.V{1, 1, 1}),
+		},
+		{
+			name: "Random/NegativeDirection",
+			data: perf.GenerateRandomBoxes(1000, k, 100, 200),
+			q:    *ray.New(vector.V{100, 100, 100}, vector.V{-1, -2, -1}),
+		},
+		// Check that rays which only graze the boundary of an object
+		// are handled consistently.
+		{
+			name: "Boundary",
+			data: map[id.ID]hyperrectangle.R{
+				100: *hyperrectangle.New(vector.V{0, 1, 0}, vector.V{1, 2, 1}),
+				101: *hyperrectangle.New(vector.V{2, -1, 0}, vector.V{3, 0, 1}),
+				102: *hyperrectangle.New(vector.V{4, 0, 0}, vector.V{5, 0, 0}),
+				103: *hyperrectangle.New(vector.V{-2, 0, 0}, vector.V{-1, 1, 1}),
+				104: *hyperrectangle.New(vector.V{6, 0.5, 0.5}, vector.V{7, 1, 1}),
+			},
+			q: *ray.New(vector.V{0, 0, 0}, vector.V{1, 0, 0}),
+		},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			tbvh := New(O{
+				K:         k,
+				LeafSize:  2,
+				Tolerance: 1.05,
+			})
+
+			want := []id.ID{}
+			for x, h := range c.data {
+				tbvh.Insert(x, h)
+				if _, ok := query.IntersectT(c.q, h); ok {
+					want = append(want, x)
+				}
+			}
+
+			got := []id.ID{}
+			tbvh.RaycastFunc(c.q, func(x id.ID) bool {
+				got = append(got, x)
+				return true
+			})
+
+			opt := cmpopts.SortSlices(func(a, b id.ID) bool { return a < b })
+			if diff := cmp.Diff(want, got, opt); diff != "" {
+				t.Errorf("RaycastFunc() mismatch (-want +got):\n%v", diff)
+			}
+			if diff := cmp.Diff(tbvh.Raycast(c.q), got, opt); diff != "" {
+				t.Errorf("RaycastFunc() mismatch with Raycast() (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestPairsConformance(t *testing.T) {
+	const k = 3
+	type config struct {
+		size int
+		data map[id.ID]hyperrectangle.R
+	}
+
+	configs := []config{
+		{
+			size: 4,
+			data: map[id.ID]hyperrectangle.R{},
+		},
+		{
+			size: 1,
+			data: map[id.ID]hyperrectangle.R{
+				100: *hyperrectangle.New(
+					vector.V([]float64{0, 0, 0}),
+					vector.V([]float64{1, 1, 1}),
+				),
+				101: *hyperrectangle.New(
+					vector.V([]float64{1, 1, 1}),
+					vector.V([]float64{2, 2, 2}),
+				),
+				102: *hyperrectangle.New(
+					vector.V([]float64{3, 3, 3}),
+					vector.V([]float64{4, 4, 4}),
+				),
+			},
+		},
+		{
+			size: 1,
+			data: perf.GenerateRandomBoxes(200, k, 100, 200),
+		},
+		{
+			size: 4,
+			data: perf.GenerateRandomBoxes(200, k, 100, 200),
+		},
+	}
+
+	for _, c := range configs {
+		t.Run(fmt.Sprintf("BVH/K=%v/LeafSize=%v/N=%v", k, c.size, len(c.data)), func(t *testing.T) {
+			tbvh := New(O{
+				K:         k,
+				LeafSize:  c.size,
+				Tolerance: 1.05,
+			})
+
+			want := []query.P{}
+			for x, h := range c.data {
+				tbvh.Insert(x, h)
+				for y, g := range c.data {
+					if x < y && !hyperrectangle.Disjoint(h, g) {
+						want = append(want, query.P{A: x, B: y})
+					}
+				}
+			}
+
+			if diff := cmp.Diff(
+				want, tbvh.Pairs(),
+				cmpopts.SortSlices(func(a, b query.P) bool { return a.A < b.A || a.A == b.A && a.B < b.B }),
+			); diff != "" {
+				t.Errorf("Pairs() mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestCrossPairsConformance(t *testing.T) {
+	const k = 3
+	type config struct {
+		s map[id.ID]hyperrectangle.R
+		t map[id.ID]hyperrectangle.R
+	}
+
+	configs := []config{
+		{
+			s: map[id.ID]hyperrectangle.R{},
+			t: perf.GenerateRandomBoxes(100, k, 100, 200),
+		},
+		{
+			s: map[id.ID]hyperrectangle.R{
+				100: *hyperrectangle.New(
+					vector.V([]float64{0, 0, 0}),
+					vector.V([]float64{1, 1, 1}),
+				),
+			},
+			t: map[id.ID]hyperrectangle.R{
+				100: *hyperrectangle.New(
+					vector.V([]float64{1, 1, 1}),
+					vector.V([]float64{2, 2, 2}),
+				),
+				101: *hyperrectangle.New(
+					vector.V([]float64{3, 3, 3}),
+					vector.V([]float64{4, 4, 4}),
+				),
+			},
+		},
+		{
+			s: perf.GenerateRandomBoxes(200, k, 100, 200),
+			t: perf.GenerateRandomBoxes(100, k, 100, 200),
+		},
+	}
+
+	for _, c := range configs {
+		t.Run(fmt.Sprintf("BVH/K=%v/N=%v/M=%v", k, len(c.s), len(c.t)), func(t *testing.T) {
+			s := New(O{
+				K:         k,
+				LeafSize:  4,
+				Tolerance: 1,
+			})
+			u := New(O{
+				K:         k,
+				LeafSize:  2,
+				Tolerance: 1.2,
+			})
+
+			for x, h := range c.s {
+				s.Insert(x, h)
+			}
+			for x, h := range c.t {
+				u.Insert(x, h)
+			}
+
+			want := []query.P{}
+			for x, h := range c.s {
+				for y, g := range c.t {
+					if !hyperrectangle.Disjoint(h, g) {
+						want = append(want, query.P{A: x, B: y})
+					}
+				}
+			}
+
+			if diff := cmp.Diff(
+				want, CrossPairs(s, u),
+				cmpopts.SortSlices(func(a, b query.P) bool { return a.A < b.A || a.A == b.A && a.B < b.B }),
+			); diff != "" {
+				t.Errorf("CrossPairs() mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestNewFromData(t *testing.T) {
+	const k = 3
+	for _, c := range []struct {
+		size  int
+		build Build
+	}{
+		{size: 1, build: BuildSAH},
+		{size: 4, build: BuildSAH},
+		{size: 1, build: BuildMorton},
+		{size: 4, build: BuildMorton},
+		{size: 4, build: BuildMortonOptimized},
+		{size: 1, build: BuildSAHRestructured},
+		{size: 4, build: BuildMortonRestructured},
+	} {
+		size := c.size
+		t.Run(fmt.Sprintf("BVH/K=%v/LeafSize=%v/Build=%v", k, size, c.build), func(t *testing.T) {
+			data := perf.GenerateRandomBoxes(1000, k, 100, 200)
+			q := perf.GenerateAABB(k, 50, 70)
+
+			tbf := bruteforce.New()
+			for x, h := range data {
+				tbf.Insert(x, h)
+			}
+			tbvh := NewFromData(O{
+				K:         k,
+				LeafSize:  size,
+				Tolerance: 1.05,
+				Build:     c.build,
+			}, data)
+
+			if err := tbvh.Validate(); err != nil {
+				t.Fatalf("Validate() = %v, want = nil", err)
+			}
+
+			// Ensure the tree remains valid after subsequent
+			// mutations.
+			for x, h := range perf.GenerateRandomBoxes(100, k, 100, 200) {
+				x := x + id.ID(len(data))
+				tbf.Insert(x, h)
+				if err := tbvh.Insert(x, h); err != nil {
+					t.Fatalf("Insert() = %v, want = nil", err)
+				}
+			}
+			for x := id.ID(0); x < 100; x++ {
+				tbf.Remove(x)
+				if err := tbvh.Remove(x); err != nil {
+					t.Fatalf("Remove() = %v, want = nil", err)
+				}
+			}
+
+			if err := tbvh.Validate(); err != nil {
+				t.Fatalf("Validate() = %v, want = nil", err)
+			}
+
+			if diff := cmp.Diff(
+				tbf.BroadPhase(q), tbvh.BroadPhase(q),
+				cmpopts.SortSlices(func(a, b id.ID) bool { return a < b }),
+			); diff != "" {
+				t.Errorf("BroadPhase() mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestStrategyConformance(t *testing.T) {
+	const k = 3
+
+	type config struct {
+		name string
+		o    O
+	}
+
+	var configs []config
+	for _, cand := range []Candidate{CandidateGuttman, CandidateBittner, CandidateCatto, CandidateDHConnelly, CandidateRStar} {
+		for _, s := range []Split{SplitGuttmanLinear, SplitDHConnelly} {
+			for _, b := range []Balance{BalanceBrianNoyamaNoDF, BalanceBrianNoyama, BalanceAVL, BalanceTreelet} {
+				for _, h := range []Heuristic{HeuristicSA, HeuristicBrianNoyama} {
+					configs = append(configs, config{
+						name: fmt.Sprintf("Candidate=%v/Split=%v/Balance=%v/Heuristic=%v", cand, s, b, h),
+						o: O{
+							K:         k,
+							LeafSize:  4,
+							Tolerance: 1.05,
+							Candidate: cand,
+							Split:     s,
+							Balance:   b,
+							Heuristic: h,
+						},
+					})
+				}
+			}
+		}
+	}
+
+	data := perf.GenerateRandomBoxes(500, k, 100, 200)
+	q := perf.GenerateAABB(k, 50, 70)
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			tbf := bruteforce.New()
+			tbvh := New(c.o)
+			for x, h := range data {
+				tbf.Insert(x, h)
+				tbvh.Insert(x, h)
+			}
+			for x := id.ID(0); x < 100; x++ {
+				tbf.Remove(x)
+				tbvh.Remove(x)
+			}
+
+			if err := tbvh.Validate(); err != nil {
+				t.Fatalf("Validate() = %v, want = nil", err)
+			}
+
+			if diff := cmp.Diff(
+				tbf.BroadPhase(q), tbvh.BroadPhase(q),
+				cmpopts.SortSlices(func(a, b id.ID) bool { return a < b }),
+			); diff != "" {
+				t.Errorf("BroadPhase() mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
+
+func TestCursor(t *testing.T) {
+	const k = 3
+
+	t.Run("Empty", func(t *testing.T) {
+		tbvh := New(O{
+			K:         k,
+			LeafSize:  4,
+			Tolerance: 1.05,
+		})
+		if c := tbvh.Cursor(); c.IsValid() {
+			t.Errorf("IsValid() = %v, want = %v", c.IsValid(), false)
+		}
+	})
+
+	t.Run("Traversal", func(t *testing.T) {
+		data := perf.GenerateRandomBoxes(500, k, 100, 200)
+		tbvh := New(O{
+			K:         k,
+			LeafSize:  4,
+			Tolerance: 1.05,
+		})
+		for x, h := range data {
+			tbvh.Insert(x, h)
+		}
+
+		root := tbvh.Cursor()
+		if !root.IsRoot() || root.Parent().IsValid() {
+			t.Fatalf("root cursor has a valid parent")
+		}
+
+		got := []id.ID{}
+		open := []cursor.C{root}
+
+		var c cursor.C
+		for len(open) > 0 {
+			c, open = open[len(open)-1], open[:len(open)-1]
+			if c.IsLeaf() {
+				if c.Height() != 0 {
+					t.Errorf("Height() = %v, want = %v", c.Height(), 0)
+				}
+				for _, x := range c.Leaves() {
+					if !hyperrectangle.Contains(c.AABB(), data[x]) {
+						t.Errorf("leaf AABB does not contain object %v", x)
+					}
+					got = append(got, x)
+				}
+				continue
+			}
+
+			l, r := c.Left(), c.Right()
+			for _, m := range []cursor.C{l, r} {
+				if !hyperrectangle.Contains(c.AABB(), m.AABB()) {
+					t.Errorf("parent AABB does not contain child AABB")
+				}
+				if m.Height() >= c.Height() {
+					t.Errorf("child Height() = %v, want < %v", m.Height(), c.Height())
+				}
+			}
+			open = append(open, l, r)
+		}
+
+		if diff := cmp.Diff(
+			tbvh.IDs(), got,
+			cmpopts.SortSlices(func(a, b id.ID) bool { return a < b }),
+		); diff != "" {
+			t.Errorf("Leaves() mismatch (-want +got):\n%v", diff)
+		}
+	})
+}
+
+func TestMarshalBinary(t *testing.T) {
+	const k = 3
+
+	configs := []O{
+		{
+			K:         k,
+			LeafSize:  4,
+			Tolerance: 1.05,
+		},
+		{
+			K:         k,
+			LeafSize:  1,
+			Tolerance: 1.2,
+			Candidate: CandidateBittner,
+			Split:     SplitDHConnelly,
+			Balance:   BalanceAVL,
+			Heuristic: HeuristicBrianNoyama,
+			Margin:    0.5,
+
+			Deterministic: true,
+		},
+	}
+
+	for _, o := range configs {
+		for _, n := range []int{0, 1, 500} {
+			t.Run(fmt.Sprintf("LeafSize=%v/Candidate=%v/N=%v", o.LeafSize, o.Candidate, n), func(t *testing.T) {
+				data := perf.GenerateRandomBoxes(n, k, 100, 200)
+				want := New(o)
+				for x, h := range data {
+					want.Insert(x, h)
+				}
+				// Ensure the cache freed pool is non-trivial.
+				for x := id.ID(0); x < id.ID(n/10); x++ {
+					want.Remove(x)
+				}
+
+				b, err := want.MarshalBinary()
+				if err != nil {
+					t.Fatalf("MarshalBinary() = _, %v, want = _, nil", err)
+				}
+
+				got := &T{}
+				if err := got.UnmarshalBinary(b); err != nil {
+					t.Fatalf("UnmarshalBinary() = %v, want = nil", err)
+				}
+
+				if diff := cmp.Diff(want.o, got.o); diff != "" {
+					t.Errorf("UnmarshalBinary() options mismatch (-want +got):\n%v", diff)
+				}
+				if want.root != got.root {
+					t.Fatalf("root = %v, want = %v", got.root, want.root)
+				}
+				if diff := cmp.Diff(want.nodes, got.nodes); diff != "" {
+					t.Errorf("nodes mismatch (-want +got):\n%v", diff)
+				}
+				if diff := cmp.Diff(want.data, got.data, cmp.AllowUnexported(hyperrectangle.R{})); diff != "" {
+					t.Errorf("data mismatch (-want +got):\n%v", diff)
+				}
+				if want.root != cid.IDInvalid && !ncmp.Equal(want.c.GetOrDie(want.root), got.c.GetOrDie(got.root)) {
+					t.Errorf("UnmarshalBinary() tree mismatch")
+				}
+
+				// Re-encoding the decoded tree should produce an
+				// identical output.
+				if c, _ := got.MarshalBinary(); !bytes.Equal(b, c) {
+					t.Errorf("MarshalBinary() output differs after round trip")
+				}
+			})
+		}
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		want := New(configs[0])
+		for x, h := range perf.GenerateRandomBoxes(10, k, 100, 200) {
+			want.Insert(x, h)
+		}
+		b, _ := want.MarshalBinary()
+
+		for i := 0; i < len(b); i++ {
+			if err := (&T{}).UnmarshalBinary(b[:i]); err == nil {
+				t.Fatalf("UnmarshalBinary() = nil, want a non-nil error for a buffer of length %v", i)
+			}
+		}
+
+		for _, v := range []byte{0, byte(version + 1), 0xff} {
+			c := append([]byte{}, b...)
+			c[len(magic)] = v
+			if err := (&T{}).UnmarshalBinary(c); err == nil {
+				t.Errorf("UnmarshalBinary() = nil, want a non-nil error for unsupported version %v", v)
+			}
+		}
+	})
+
+	// Check that corrupted node links (e.g. self-links and cycles) are
+	// rejected rather than crashing the decoder.
+	t.Run("Corrupt", func(t *testing.T) {
+		want := New(configs[0])
+		for x, h := range perf.GenerateRandomBoxes(20, k, 100, 200) {
+			want.Insert(x, h)
+		}
+		b, _ := want.MarshalBinary()
+
+		for i := len(magic) + 8; i < len(b); i++ {
+			for _, v := range []byte{0x00, 0x01, 0x02, 0xff, b[i] ^ 0x01} {
+				c := append([]byte{}, b...)
+				c[i] = v
+
+				got := &T{}
+				if err := got.UnmarshalBinary(c); err != nil {
+					continue
+				}
+				if err := got.Validate(); err != nil {
+					t.Errorf("UnmarshalBinary() decoded an invalid tree with byte %v = %v: %v", i, v, err)
+				}
+			}
+		}
+	})
+}
+
+func FuzzUnmarshalBinary(f *testing.F) {
+	tbvh := New(O{
+		K:         2,
+		LeafSize:  2,
+		Tolerance: 1.05,
+	})
+	for x, h := range perf.GenerateRandomBoxes(10, 2, 100, 200) {
+		tbvh.Insert(x, h)
+	}
+	b, _ := tbvh.MarshalBinary()
+	f.Add(b)
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		got := &T{}
+		if err := got.UnmarshalBinary(b); err != nil {
+			return
+		}
+		if err := got.Validate(); err != nil {
+			t.Errorf("UnmarshalBinary() decoded an invalid tree: %v", err)
+		}
+	})
+}
+
+func TestStats(t *testing.T) {
+	const k = 3
+
+	t.Run("Empty", func(t *testing.T) {
+		tbvh := New(O{
+			K:         k,
+			LeafSize:  4,
+			Tolerance: 1.05,
+		})
+		if diff := cmp.Diff(Stats{}, tbvh.Stats(CostAila)); diff != "" {
+			t.Errorf("Stats() mismatch (-want +got):\n%v", diff)
+		}
+	})
+
+	t.Run("Random", func(t *testing.T) {
+		data := perf.GenerateRandomBoxes(500, k, 100, 200)
+		tbvh := New(O{
+			K:         k,
+			LeafSize:  4,
+			Tolerance: 1.05,
+		})
+		for x, h := range data {
+			tbvh.Insert(x, h)
+		}
+		for x := id.ID(0); x < 50; x++ {
+			tbvh.Remove(x)
+		}
+
+		s := tbvh.Stats(CostAila)
+		if got, want := s.Objects, 450; got != want {
+			t.Errorf("Objects = %v, want = %v", got, want)
+		}
+		if got, want := s.Nodes, 2*s.Leaves-1; got != want {
+			t.Errorf("Nodes = %v, want = %v", got, want)
+		}
+		if got, want := s.Height, tbvh.Cursor().Height(); got != want {
+			t.Errorf("Height = %v, want = %v", got, want)
+		}
+		if s.LeafSizeMin < 1 || s.LeafSizeMax > 4 || s.LeafSizeAvg < float64(s.LeafSizeMin) || s.LeafSizeAvg > float64(s.LeafSizeMax) {
+			t.Errorf("invalid leaf sizes: min = %v, max = %v, avg = %v", s.LeafSizeMin, s.LeafSizeMax, s.LeafSizeAvg)
+		}
+		if got, want := s.SAH, tbvh.SAH(); got != want {
+			t.Errorf("SAH = %v, want = %v", got, want)
+		}
+		if s.Freed == 0 {
+			t.Errorf("Freed = 0, want > 0")
+		}
+		if s.Margin <= 0 || s.EPO < 0 || s.LCV < 0 {
+			t.Errorf("invalid metrics: Margin = %v, EPO = %v, LCV = %v", s.Margin, s.EPO, s.LCV)
+		}
+
+		// Stats should be deterministic for a fixed tree.
+		if diff := cmp.Diff(s, tbvh.Stats(CostAila)); diff != "" {
+			t.Errorf("Stats() mismatch (-want +got):\n%v", diff)
+		}
+	})
+}
+
+func TestValidate(t *testing.T) {
+	const k = 3
+
+	type config struct {
+		name string
+		// corrupt modifies the input valid tree.
+		corrupt func(t *T)
+		want    []Check
+	}
+
+	// leaf returns an arbitrary non-root leaf node of the tree.
+	leaf := func(t *T) node.N {
+		var n node.N
+		util.PreOrder(t.c.GetOrDie(t.root), func(m node.N) {
+			if m.IsLeaf() && !m.IsRoot() && n == nil {
+				n = m
+			}
+		})
+		return n
+	}
+
+	configs := []config{
+		{
+			name:    "Valid",
+			corrupt: func(t *T) {},
+			want:    nil,
+		},
+		{
+			name: "Link",
+			corrupt: func(t *T) {
+				n := leaf(t)
+				n.SetParent(n.ID())
+			},
+			want: []Check{CheckLink},
+		},
+		{
+			name: "Freed",
+			corrupt: func(t *T) {
+				n := leaf(t)
+				for x := range n.Leaves() {
+					delete(t.nodes, x)
+					delete(t.data, x)
+				}
+				t.c.DeleteOrDie(n.ID())
+			},
+			want: []Check{CheckFreed},
+		},
+		{
+			name: "Heuristic",
+			corrupt: func(t *T) {
+				n := leaf(t)
+				n.SetHeuristic(n.Heuristic() + 1)
+			},
+			want: []Check{CheckHeuristic},
+		},
+		{
+			name: "Height",
+			corrupt: func(t *T) {
+				n := t.c.GetOrDie(t.root)
+				n.SetHeight(n.Height() + 1)
+			},
+			want: []Check{CheckHeight},
+		},
+		{
+			name: "Object",
+			corrupt: func(t *T) {
+				n := leaf(t)
+				for x := range n.Leaves() {
+					t.nodes[x] = t.root
+				}
+			},
+			want: []Check{CheckObject},
+		},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			tbvh := New(O{
+				K:         k,
+				LeafSize:  4,
+				Tolerance: 1.05,
+			})
+			for x, h := range perf.GenerateRandomBoxes(100, k, 100, 200) {
+				tbvh.Insert(x, h)
+			}
+			c.corrupt(tbvh)
+
+			err := tbvh.Validate()
+			if c.want == nil {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want = nil", err)
+				}
+				return
+			}
+
+			var e *ValidationError
+			if !errors.As(err, &e) {
+				t.Fatalf("Validate() = %v, want a non-nil *ValidationError", err)
+			}
+			got := map[Check]bool{}
+			for _, v := range e.Violations {
+				got[v.Check] = true
+			}
+			for _, w := range c.want {
+				if !got[w] {
+					t.Errorf("Validate() = %v, want a violation of type %v", err, w)
+				}
+			}
+		})
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	const k = 3
+
+	for _, n := range []int{0, 1, 1000} {
+		for _, size := range []int{1, 4} {
+			t.Run(fmt.Sprintf("LeafSize=%v/N=%v", size, n), func(t *testing.T) {
+				data := perf.GenerateRandomBoxes(n, k, 100, 200)
+				tbvh := New(O{
+					K:         k,
+					LeafSize:  size,
+					Tolerance: 1.05,
+				})
+				for x, h := range data {
+					tbvh.Insert(x, h)
+				}
+
+				q := perf.GenerateAABB(k, 100, 200)
+				r := *ray.New(q.Min(), vector.V{1, 2, 3})
+				p := vector.V{50, 50, 50}
+
+				wantBroadPhase := tbvh.BroadPhase(q)
+				wantRaycast := tbvh.Raycast(r)
+				wantQuery := tbvh.Query(func(r hyperrectangle.R) bool { return r.Min().X(0) < 500 })
+				wantKNN := tbvh.KNN(p, 10)
+				// Ensure there are ties between objects which
+				// contain the query point.
+				wantKNNTies := tbvh.KNN(q.Min(), 50)
+
+				s := tbvh.Snapshot()
+
+				// Mutations to the source tree must not affect the
+				// snapshot.
+				for x := range data {
+					if x%2 == 0 {
+						tbvh.Remove(x)
+					} else {
+						tbvh.Update(x, perf.GenerateAABB(k, 100, 200))
+					}
+				}
+
+				if got, want := s.Len(), n; got != want {
+					t.Errorf("Len() = %v, want = %v", got, want)
+				}
+
+				opt := cmpopts.SortSlices(func(a, b id.ID) bool { return a < b })
+				if diff := cmp.Diff(wantBroadPhase, s.BroadPhase(q), opt); diff != "" {
+					t.Errorf("BroadPhase() mismatch (-want +got):\n%v", diff)
+				}
+				if diff := cmp.Diff(wantRaycast, s.Raycast(r), opt); diff != "" {
+					t.Errorf("Raycast() mismatch (-want +got):\n%v", diff)
+				}
+				if diff := cmp.Diff(wantQuery, s.Query(func(r hyperrectangle.R) bool { return r.Min().X(0) < 500 }), opt); diff != "" {
+					t.Errorf("Query() mismatch (-want +got):\n%v", diff)
+				}
+
+				if diff := cmp.Diff(wantKNN, s.KNN(p, 10)); diff != "" {
+					t.Errorf("KNN() mismatch (-want +got):\n%v", diff)
+				}
+				if diff := cmp.Diff(wantKNNTies, s.KNN(q.Min(), 50)); diff != "" {
+					t.Errorf("KNN() mismatch (-want +got):\n%v", diff)
+				}
+			})
+		}
+	}
+}
+
+func TestClone(t *testing.T) {
+	const k = 3
+
+	for _, n := range []int{0, 1, 500} {
+		t.Run(fmt.Sprintf("N=%v", n), func(t *testing.T) {
+			data := perf.GenerateRandomBoxes(n, k, 100, 200)
+			want := New(O{
+				K:         k,
+				LeafSize:  4,
+				Tolerance: 1.05,
+			})
+			for x, h := range data {
+				want.Insert(x, h)
+			}
+			for x := id.ID(0); x < id.ID(n/10); x++ {
+				want.Remove(x)
+			}
+
+			got := want.Clone()
+			if want.root != got.root {
+				t.Fatalf("root = %v, want = %v", got.root, want.root)
+			}
+			if want.root != cid.IDInvalid && !ncmp.Equal(want.c.GetOrDie(want.root), got.c.GetOrDie(got.root)) {
+				t.Errorf("Clone() tree mismatch")
+			}
+			if diff := cmp.Diff(want.nodes, got.nodes); diff != "" {
+				t.Errorf("nodes mismatch (-want +got):\n%v", diff)
+			}
+
+			b, _ := want.MarshalBinary()
+
+			// Mutating the clone must not affect the source tree.
+			for x := range got.data {
+				if x%2 == 0 {
+					got.Remove(x)
+				} else {
+					got.Update(x, perf.GenerateAABB(k, 100, 200))
+				}
+			}
+			got.Insert(id.ID(n), perf.GenerateAABB(k, 100, 200))
+
+			if c, _ := want.MarshalBinary(); !bytes.Equal(b, c) {
+				t.Errorf("source tree was mutated by the clone")
+			}
+			for _, u := range []*T{want, got} {
+				if err := u.Validate(); err != nil {
+					t.Errorf("Validate() = %v, want = nil", err)
+				}
+			}
+		})
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	const k = 3
+
+	type result struct {
+		b          []byte
+		ids        []id.ID
+		broadphase []id.ID
+		raycast    []id.ID
+		f          []id.ID
+		pairs      []query.P
+		knn        []id.ID
+
+		// leaves lists the objects of each leaf node visited by a
+		// pre-order cursor traversal.
+		leaves [][]id.ID
+	}
+
+	data := perf.GenerateRandomBoxes(200, k, 100, 200)
+	xs := make([]id.ID, 0, len(data))
+	for x := range data {
+		xs = append(xs, x)
+	}
+	sort.Slice(xs, func(i, j int) bool { return xs[i] < xs[j] })
+
+	moves := make([]hyperrectangle.R, 0, len(xs))
+	for range xs {
+		moves = append(moves, perf.GenerateAABB(k, 100, 200))
+	}
+
+	q := perf.GenerateAABB(k, 100, 200)
+	r := *ray.New(q.Min(), vector.V{1, 2, 3})
+
+	// run applies a fixed sequence of operations to a new tree.
+	run := func(t *testing.T, o O) result {
+		tbvh := New(o)
+		for _, x := range xs {
+			tbvh.Insert(x, data[x])
+		}
+		for i, x := range xs {
+			switch i % 3 {
+			case 0:
+				tbvh.Remove(x)
+			case 1:
+				tbvh.Update(x, moves[i])
+			}
+		}
+
+		var f []id.ID
+		tbvh.BroadPhaseFunc(q, func(x id.ID) bool {
+			f = append(f, x)
+			return true
+		})
+
+		var leaves [][]id.ID
+		var visit func(c cursor.C)
+		visit = func(c cursor.C) {
+			if !c.IsValid() {
+				return
+			}
+			if c.IsLeaf() {
+				leaves = append(leaves, c.Leaves())
+			}
+			visit(c.Left())
+			visit(c.Right())
+		}
+		visit(tbvh.Cursor())
+
+		// Snapshot queries return the same results as the tree.
+		s := tbvh.Snapshot()
+		for _, c := range []struct {
+			name      string
+			got, want []id.ID
+		}{
+			{name: "BroadPhase", got: s.BroadPhase(q), want: tbvh.BroadPhase(q)},
+			{name: "Raycast", got: s.Raycast(r), want: tbvh.Raycast(r)},
+			{name: "KNN", got: s.KNN(q.Min(), 50), want: tbvh.KNN(q.Min(), 50)},
+		} {
+			if diff := cmp.Diff(c.want, c.got); diff != "" {
+				t.Errorf("Snapshot().%v() mismatch (-want +got):\n%v", c.name, diff)
+			}
+		}
+
+		b, _ := tbvh.MarshalBinary()
+		return result{
+			leaves:     leaves,
+			b:          b,
+			ids:        tbvh.IDs(),
+			broadphase: tbvh.BroadPhase(q),
+			raycast:    tbvh.Raycast(r),
+			f:          f,
+			pairs:      tbvh.Pairs(),
+			// Ensure there are ties between objects which contain
+			// the query point.
+			knn: tbvh.KNN(q.Min(), 50),
+		}
+	}
+
+	for _, size := range []int{1, 4} {
+		for _, s := range []Split{SplitGuttmanLinear, SplitDHConnelly} {
+			t.Run(fmt.Sprintf("LeafSize=%v/Split=%v", size, s), func(t *testing.T) {
+				o := O{
+					K:             k,
+					LeafSize:      size,
+					Tolerance:     1.05,
+					Split:         s,
+					Deterministic: true,
+				}
+
+				want := run(t, o)
+				for i := 0; i < 3; i++ {
+					if diff := cmp.Diff(want, run(t, o), cmp.AllowUnexported(result{})); diff != "" {
+						t.Fatalf("run() mismatch (-want +got):\n%v", diff)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestMove(t *testing.T) {
+	const k = 2
+
+	t.Run("Prediction", func(t *testing.T) {
+		tbvh := New(O{
+			K:         k,
+			LeafSize:  1,
+			Tolerance: 1.05,
+		})
+		for x, h := range perf.GenerateRandomBoxes(100, k, 100, 200) {
+			tbvh.Insert(x, h)
+		}
+
+		// Move an object at a constant velocity. After the first
+		// reinsertion, the object should remain in the same leaf for
+		// the predicted displacement.
+		const x = 1000
+		d := vector.V{5, -2}
+		aabb := *hyperrectangle.New(vector.V{0, 0}, vector.V{1, 1})
+		tbvh.Insert(x, aabb)
+
+		step := func(aabb hyperrectangle.R) hyperrectangle.R {
+			return *hyperrectangle.New(vector.Add(aabb.Min(), d), vector.Add(aabb.Max(), d))
+		}
+
+		aabb = step(aabb)
+		if err := tbvh.Move(x, aabb, vector.Scale(10, d)); err != nil {
+			t.Fatalf("Move() = %v, want = nil", err)
+		}
+		n := tbvh.nodes[x]
+		for i := 0; i < 10; i++ {
+			aabb = step(aabb)
+			if err := tbvh.Move(x, aabb, vector.Scale(10, d)); err != nil {
+				t.Fatalf("Move() = %v, want = nil", err)
+			}
+			if got := tbvh.nodes[x]; got != n {
+				t.Fatalf("Move() reinserted the object into node %v at step %v, want = %v", got, i, n)
+			}
+		}
+
+		if err := tbvh.Validate(); err != nil {
+			t.Errorf("Validate() = %v, want = nil", err)
+		}
+	})
+
+	t.Run("Conformance", func(t *testing.T) {
+		data := perf.GenerateRandomBoxes(500, k, 100, 200)
+		tbf := bruteforce.New()
+		tbvh := New(O{
+			K:         k,
+			LeafSize:  4,
+			Tolerance: 1.05,
+		})
+		for x, h := range data {
+			tbf.Insert(x, h)
+			tbvh.Insert(x, h)
+		}
+
+		for i := 0; i < 10; i++ {
+			for x := range data {
+				h := perf.GenerateAABB(k, 100, 200)
+				d := vector.V{rand.Float64()*20 - 10, rand.Float64()*20 - 10}
+				if err := tbvh.Move(x, h, d); err != nil {
+					t.Fatalf("Move() = %v, want = nil", err)
+				}
+				tbf.Update(x, h)
+			}
+
+			if err := tbvh.Validate(); err != nil {
+				t.Fatalf("Validate() = %v, want = nil", err)
+			}
+
+			q := perf.GenerateAABB(k, 100, 200)
+			if diff := cmp.Diff(
+				tbf.BroadPhase(q), tbvh.BroadPhase(q),
+				cmpopts.SortSlices(func(a, b id.ID) bool { return a < b }),
+			); diff != "" {
+				t.Errorf("BroadPhase() mismatch (-want +got):\n%v", diff)
+			}
+		}
+
+		// Clones and encoded trees should preserve the swept bounds.
+		if diff := cmp.Diff(tbvh.bounds, tbvh.Clone().bounds, cmp.AllowUnexported(hyperrectangle.R{})); diff != "" {
+			t.Errorf("Clone() bounds mismatch (-want +got):\n%v", diff)
+		}
+		b, _ := tbvh.MarshalBinary()
+		u := &T{}
+		if err := u.UnmarshalBinary(b); err != nil {
+			t.Fatalf("UnmarshalBinary() = %v, want = nil", err)
+		}
+		if diff := cmp.Diff(tbvh.bounds, u.bounds, cmp.AllowUnexported(hyperrectangle.R{})); diff != "" {
+			t.Errorf("UnmarshalBinary() bounds mismatch (-want +got):\n%v", diff)
+		}
+	})
+
+	t.Run("InvalidDisplacement", func(t *testing.T) {
+		tbvh := New(O{
+			K:         k,
+			LeafSize:  1,
+			Tolerance: 1.05,
+		})
+		aabb := *hyperrectangle.New(vector.V{0, 0}, vector.V{1, 1})
+		tbvh.Insert(0, aabb)
+		if err := tbvh.Move(0, aabb, vector.V{1, 1, 1}); err == nil {
+			t.Errorf("Move() = nil, want a non-nil error")
+		}
+	})
+}
+
+func TestMargin(t *testing.T) {
+	const k = 2
+
+	t.Run("Point", func(t *testing.T) {
+		type config struct {
+			name string
+			o    O
+			// want indicates if the object should remain in the same
+			// leaf after a small move.
+			want bool
+		}
+
+		configs := []config{
+			{
+				name: "Relative",
+				o:    O{K: k, LeafSize: 1, Tolerance: 2},
+				want: false,
+			},
+			{
+				name: "Absolute",
+				o:    O{K: k, LeafSize: 1, Tolerance: 1, Margin: 1},
+				want: true,
+			},
+			{
+				name: "Combined",
+				o:    O{K: k, LeafSize: 1, Tolerance: 2, Margin: 1},
+				want: true,
+			},
+		}
+
+		for _, c := range configs {
+			t.Run(c.name, func(t *testing.T) {
+				tbvh := New(c.o)
+				tbvh.Insert(0, *hyperrectangle.New(vector.V{10, 10}, vector.V{11, 11}))
+				tbvh.Insert(1, *hyperrectangle.New(vector.V{0, 0}, vector.V{0, 0}))
+
+				n := tbvh.nodes[1]
+				tbvh.Update(1, *hyperrectangle.New(vector.V{0.5, -0.5}, vector.V{0.5, -0.5}))
+				if got := tbvh.nodes[1] == n && tbvh.c.GetOrDie(n).IsLeaf(); got != c.want {
+					t.Errorf("Update() kept the object in the same leaf = %v, want = %v", got, c.want)
+				}
+				if err := tbvh.Validate(); err != nil {
+					t.Errorf("Validate() = %v, want = nil", err)
+				}
+			})
+		}
+	})
+
+	t.Run("Conformance", func(t *testing.T) {
+		data := perf.GenerateRandomBoxes(500, k, 100, 200)
+		tbf := bruteforce.New()
+		tbvh := New(O{
+			K:         k,
+			LeafSize:  4,
+			Tolerance: 1.05,
+			Margin:    2,
+		})
+		for x, h := range data {
+			tbf.Insert(x, h)
+			tbvh.Insert(x, h)
+		}
+
+		for i := 0; i < 10; i++ {
+			for x := range data {
+				h := perf.GenerateAABB(k, 100, 200)
+				if x%2 == 0 {
+					tbvh.Update(x, h)
+				} else {
+					tbvh.Move(x, h, vector.V{1, -1})
+				}
+				tbf.Update(x, h)
+			}
+			if err := tbvh.Validate(); err != nil {
+				t.Fatalf("Validate() = %v, want = nil", err)
+			}
+
+			q := perf.GenerateAABB(k, 100, 200)
+			if diff := cmp.Diff(
+				tbf.BroadPhase(q), tbvh.BroadPhase(q),
+				cmpopts.SortSlices(func(a, b id.ID) bool { return a < b }),
+			); diff != "" {
+				t.Errorf("BroadPhase() mismatch (-want +got):\n%v", diff)
+			}
+		}
+
+		u := NewFromData(tbvh.o, data)
+		if err := u.Validate(); err != nil {
+			t.Fatalf("Validate() = %v, want = nil", err)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, m := range []float64{-1, math.Inf(1), math.NaN()} {
+			if err := (O{K: k, LeafSize: 1, Tolerance: 1, Margin: m}).validate(); err == nil {
+				t.Errorf("validate() = nil, want a non-nil error for margin %v", m)
+			}
+		}
+	})
+}
+
+func TestInsertWithOptions(t *testing.T) {
+	const k = 2
+
+	r := func(min, max vector.V) hyperrectangle.R { return *hyperrectangle.New(min, max) }
+	leaf := func(tbvh *T, x id.ID) hyperrectangle.R {
+		return tbvh.c.GetOrDie(tbvh.nodes[x]).AABB().R()
+	}
+
+	// check verifies the leaf AABBs of the static, dynamic, and fast
+	// objects.
+	check := func(t *testing.T, tbvh *T) {
+		if err := tbvh.Validate(); err != nil {
+			t.Fatalf("Validate() = %v, want = nil", err)
+		}
+		if got, want := leaf(tbvh, 0), tbvh.data[0]; !hyperrectangle.Within(got, want) {
+			t.Errorf("static leaf AABB = %v, want = %v", got, want)
+		}
+		if got, want := leaf(tbvh, 1), r(vector.V{19, 19}, vector.V{22, 22}); !hyperrectangle.Within(got, want) {
+			t.Errorf("dynamic leaf AABB = %v, want = %v", got, want)
+		}
+		if got, want := leaf(tbvh, 2), r(vector.V{35, 35}, vector.V{46, 46}); !hyperrectangle.Within(got, want) {
+			t.Errorf("fast leaf AABB = %v, want = %v", got, want)
+		}
+	}
+
+	margin := 5.0
+
+	tbvh := New(O{
+		K:         k,
+		LeafSize:  1,
+		Tolerance: 1,
+		Margin:    1,
+	})
+	if err := tbvh.InsertWithOptions(0, r(vector.V{0, 0}, vector.V{10, 1}), InsertO{Static: true, Margin: &margin}); err != nil {
+		t.Fatalf("InsertWithOptions() = %v, want = nil", err)
+	}
+	if err := tbvh.Insert(1, r(vector.V{20, 20}, vector.V{21, 21})); err != nil {
+		t.Fatalf("Insert() = %v, want = nil", err)
+	}
+	if err := tbvh.InsertWithOptions(2, r(vector.V{40, 40}, vector.V{41, 41}), InsertO{Margin: &margin}); err != nil {
+		t.Fatalf("InsertWithOptions() = %v, want = nil", err)
+	}
+	check(t, tbvh)
+
+	// The tree keeps a copy of the input margin.
+	margin = 100
+	tbvh.Update(2, r(vector.V{-40, -40}, vector.V{-39, -39}))
+	tbvh.Update(2, r(vector.V{40, 40}, vector.V{41, 41}))
+	check(t, tbvh)
+
+	// Options are kept across reinsertions.
+	tbvh.Update(0, r(vector.V{-20, -20}, vector.V{-10, -19}))
+	tbvh.Update(2, r(vector.V{-40, -40}, vector.V{-39, -39}))
+	tbvh.Update(2, r(vector.V{40, 40}, vector.V{41, 41}))
+	check(t, tbvh)
+
+	t.Run("Clone", func(t *testing.T) { check(t, tbvh.Clone()) })
+	t.Run("MarshalBinary", func(t *testing.T) {
+		b, err := tbvh.MarshalBinary()
+		if err != nil {
+			t.Fatalf("MarshalBinary() = %v, want = nil", err)
+		}
+		u := &T{}
+		if err := u.UnmarshalBinary(b); err != nil {
+			t.Fatalf("UnmarshalBinary() = %v, want = nil", err)
+		}
+		check(t, u)
+
+		// The decoded tree tracks the object options.
+		u.Update(0, r(vector.V{0, 0}, vector.V{10, 1}))
+		check(t, u)
+	})
+	t.Run("Remove", func(t *testing.T) {
+		u := tbvh.Clone()
+		u.Remove(0)
+		u.Insert(0, r(vector.V{0, 0}, vector.V{10, 1}))
+		if got, want := leaf(u, 0), r(vector.V{-1, -1}, vector.V{11, 2}); !hyperrectangle.Within(got, want) {
+			t.Errorf("leaf AABB = %v, want = %v", got, want)
+		}
+	})
+	t.Run("Tolerance", func(t *testing.T) {
+		u := New(O{K: k, LeafSize: 2, Tolerance: 4})
+		u.InsertWithOptions(0, r(vector.V{0, 0}, vector.V{1, 1}), InsertO{Static: true})
+		u.InsertWithOptions(1, r(vector.V{1, 1}, vector.V{2, 2}), InsertO{Static: true})
+		if got, want := leaf(u, 0), r(vector.V{0, 0}, vector.V{2, 2}); !hyperrectangle.Within(got, want) {
+			t.Errorf("static leaf AABB = %v, want = %v", got, want)
+		}
+
+		// Leaves which contain a dynamic object are expanded.
+		u.Insert(2, r(vector.V{0, 1}, vector.V{1, 2}))
+		n := u.c.GetOrDie(u.nodes[2])
+		xs := make([]id.ID, 0, len(n.Leaves()))
+		for x := range n.Leaves() {
+			xs = append(xs, x)
+		}
+		if got, want := hyperrectangle.V(n.AABB().R()), 4*hyperrectangle.V(node.Union(u.data, xs...)); math.Abs(got-want) > 1e-10 {
+			t.Errorf("dynamic leaf volume = %v, want = %v", got, want)
+		}
+	})
+	t.Run("ZeroMargin", func(t *testing.T) {
+		zero := 0.0
+
+		u := tbvh.Clone()
+		if err := u.InsertWithOptions(3, r(vector.V{60, 60}, vector.V{61, 61}), InsertO{Margin: &zero}); err != nil {
+			t.Fatalf("InsertWithOptions() = %v, want = nil", err)
+		}
+		if got, want := leaf(u, 3), r(vector.V{60, 60}, vector.V{61, 61}); !hyperrectangle.Within(got, want) {
+			t.Errorf("leaf AABB = %v, want = %v", got, want)
+		}
+
+		b, err := u.MarshalBinary()
+		if err != nil {
+			t.Fatalf("MarshalBinary() = %v, want = nil", err)
+		}
+		v := &T{}
+		if err := v.UnmarshalBinary(b); err != nil {
+			t.Fatalf("UnmarshalBinary() = %v, want = nil", err)
+		}
+		v.Update(3, r(vector.V{-60, -60}, vector.V{-59, -59}))
+		if got, want := leaf(v, 3), r(vector.V{-60, -60}, vector.V{-59, -59}); !hyperrectangle.Within(got, want) {
+			t.Errorf("decoded leaf AABB = %v, want = %v", got, want)
+		}
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		for _, m := range []float64{-1, math.Inf(1), math.NaN()} {
+			m := m
+			if err := tbvh.InsertWithOptions(3, r(vector.V{0, 0}, vector.V{1, 1}), InsertO{Margin: &m}); err == nil {
+				t.Errorf("InsertWithOptions() = nil, want a non-nil error for margin %v", m)
+			}
+		}
+	})
+}
+
+func TestOptimize(t *testing.T) {
+	const k = 2
+
+	data := perf.GenerateRandomBoxes(1000, k, 100, 200)
+	tbf := bruteforce.New()
+	tbvh := New(O{
+		K:         k,
+		LeafSize:  4,
+		Tolerance: 1.05,
+	})
+	for x, h := range data {
+		tbf.Insert(x, h)
+		tbvh.Insert(x, h)
+	}
+	for x := range data {
+		h := perf.GenerateAABB(k, 100, 200)
+		tbf.Update(x, h)
+		tbvh.Update(x, h)
+	}
+
+	if got := tbvh.Optimize(Budget{Nodes: 10}); got != 10 {
+		t.Errorf("Optimize() = %v, want = %v", got, 10)
+	}
+	if err := tbvh.Validate(); err != nil {
+		t.Fatalf("Validate() = %v, want = nil", err)
+	}
+
+	before := tbvh.SAH()
+	tbvh.Optimize(Budget{})
+	if err := tbvh.Validate(); err != nil {
+		t.Fatalf("Validate() = %v, want = nil", err)
+	}
+	if after := tbvh.SAH(); after > before {
+		t.Errorf("SAH() = %v, want <= %v", after, before)
+	}
+
+	for i := 0; i < 10; i++ {
+		q := perf.GenerateAABB(k, 100, 200)
+		if diff := cmp.Diff(
+			tbf.BroadPhase(q), tbvh.BroadPhase(q),
+			cmpopts.SortSlices(func(a, b id.ID) bool { return a < b }),
+		); diff != "" {
+			t.Errorf("BroadPhase() mismatch (-want +got):\n%v", diff)
+		}
+	}
+
+	// The optimized tree supports the usual mutations.
+	for x := range data {
+		if err := tbvh.Remove(x); err != nil {
+			t.Fatalf("Remove() = %v, want = nil", err)
+		}
+	}
+	if got := tbvh.Optimize(Budget{}); got != 0 {
+		t.Errorf("Optimize() = %v, want = 0", got)
+	}
+}
+
+func TestRefit(t *testing.T) {
+	const k = 3
+
+	data := perf.GenerateRandomBoxes(1000, k, 100, 200)
+	tbf := bruteforce.New()
+	tbvh := NewFromData(O{
+		K:         k,
+		LeafSize:  4,
+		Tolerance: 1.05,
+		Margin:    0.5,
+	}, data)
+	for x, h := range data {
+		tbf.Insert(x, h)
+	}
+
+	if got := tbvh.Degradation(); got != 1 {
+		t.Errorf("Degradation() = %v, want = 1", got)
+	}
+
+	nodes := map[id.ID]cid.ID{}
+	for x, n := range tbvh.nodes {
+		nodes[x] = n
+	}
+	root := tbvh.root
+
+	r := rand.New(rand.NewSource(0))
+	for i := 0; i < 10; i++ {
+		updates := map[id.ID]hyperrectangle.R{}
+		for x := range data {
+			if r.Intn(2) == 0 {
+				continue
+			}
+			h := tbf[x]
+			d := vector.V(make([]float64, k))
+			for j := range d {
+				d[j] = 2*r.Float64() - 1
+			}
+			updates[x] = *hyperrectangle.New(vector.Add(h.Min(), d), vector.Add(h.Max(), d))
+		}
+		if err := tbvh.Refit(updates); err != nil {
+			t.Fatalf("Refit() = %v, want = nil", err)
+		}
+		for x, h := range updates {
+			tbf.Update(x, h)
+		}
+
+		if err := tbvh.Validate(); err != nil {
+			t.Fatalf("Validate() = %v, want = nil", err)
+		}
+
+		q := perf.GenerateAABB(k, 100, 200)
+		if diff := cmp.Diff(
+			tbf.BroadPhase(q), tbvh.BroadPhase(q),
+			cmpopts.SortSlices(func(a, b id.ID) bool { return a < b }),
+		); diff != "" {
+			t.Errorf("BroadPhase() mismatch (-want +got):\n%v", diff)
+		}
+	}
+
+	if tbvh.root != root {
+		t.Errorf("Refit() changed the root from %v to %v", root, tbvh.root)
+	}
+	if diff := cmp.Diff(nodes, tbvh.nodes); diff != "" {
+		t.Errorf("Refit() changed the object leaves (-want +got):\n%v", diff)
+	}
+
+	t.Run("Scatter", func(t *testing.T) {
+		u := tbvh.Clone()
+		updates := map[id.ID]hyperrectangle.R{}
+		for x := range data {
+			updates[x] = perf.GenerateAABB(k, 100, 200)
+		}
+		if err := u.Refit(updates); err != nil {
+			t.Fatalf("Refit() = %v, want = nil", err)
+		}
+		if got := u.Degradation(); !(got > 1.5) {
+			t.Errorf("Degradation() = %v, want > 1.5", got)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		u := tbvh.Clone()
+		for _, updates := range []map[id.ID]hyperrectangle.R{
+			{
+				0:    *hyperrectangle.New(vector.V{0, 0, 0}, vector.V{1, 1, 1}),
+				1000: *hyperrectangle.New(vector.V{0, 0, 0}, vector.V{1, 1, 1}),
+			},
+			{
+				0: *hyperrectangle.New(vector.V{0, 0}, vector.V{1, 1}),
+			},
+		} {
+			if err := u.Refit(updates); err == nil {
+				t.Errorf("Refit() = nil, want a non-nil error")
+			}
+		}
+		if diff := cmp.Diff(tbvh.data[0], u.data[0], cmp.AllowUnexported(hyperrectangle.R{})); diff != "" {
+			t.Errorf("Refit() mutated the tree on error (-want +got):\n%v", diff)
+		}
+	})
+
+	// A tree of coincident points has a zero cost, which is still a valid
+	// baseline.
+	t.Run("Zero", func(t *testing.T) {
+		u := New(O{
+			K:         k,
+			LeafSize:  1,
+			Tolerance: 1,
+		})
+		p := *hyperrectangle.New(vector.V{0, 0, 0}, vector.V{0, 0, 0})
+		for x := id.ID(0); x < 4; x++ {
+			u.Insert(x, p)
+		}
+
+		if err := u.Refit(map[id.ID]hyperrectangle.R{0: *hyperrectangle.New(vector.V{0, 0, 0}, vector.V{1, 1, 1})}); err != nil {
+			t.Fatalf("Refit() = %v, want = nil", err)
+		}
+		if got := u.Degradation(); !math.IsInf(got, 1) {
+			t.Errorf("Degradation() = %v, want = %v", got, math.Inf(1))
+		}
+
+		if err := u.Refit(map[id.ID]hyperrectangle.R{0: p}); err != nil {
+			t.Fatalf("Refit() = %v, want = nil", err)
+		}
+		if got := u.Degradation(); got != 1 {
+			t.Errorf("Degradation() = %v, want = 1", got)
+		}
+	})
+
+	t.Run("Reset", func(t *testing.T) {
+		u := tbvh.Clone()
+		if got, want := u.Degradation(), tbvh.Degradation(); got != want {
+			t.Errorf("Degradation() = %v, want = %v", got, want)
+		}
+		u.Remove(0)
+		if got := u.Degradation(); got != 1 {
+			t.Errorf("Degradation() = %v, want = 1", got)
+		}
+	})
+}
+
+func TestBatch(t *testing.T) {
+	const k = 3
+
+	type config struct {
+		name    string
+		size    int
+		balance Balance
+	}
+
+	// The other balance strategies may violate the AVL height constraint
+	// when reducing the tree cost, even outside of a batch, and so are not
+	// checked here.
+	var configs []config
+	for _, size := range []int{1, 4} {
+		configs = append(configs,
+			config{name: fmt.Sprintf("LeafSize=%v/Balance=BrianNoyamaNoDF", size), size: size, balance: BalanceBrianNoyamaNoDF},
+			config{name: fmt.Sprintf("LeafSize=%v/Balance=AVL", size), size: size, balance: BalanceAVL},
+		)
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			data := perf.GenerateRandomBoxes(1000, k, 100, 200)
+			tbf := bruteforce.New()
+			tbvh := New(O{
+				K:         k,
+				LeafSize:  c.size,
+				Tolerance: 1.05,
+				Balance:   c.balance,
+			})
+
+			check := func(t *testing.T) {
+				t.Helper()
+				if err := tbvh.Validate(); err != nil {
+					t.Fatalf("Validate() = %v, want = nil", err)
+				}
+				// The batch ops maintain the AVL height constraint.
+				if n, ok := tbvh.c.Get(tbvh.root); ok {
+					util.PreOrder(n, func(n node.N) {
+						if n.IsLeaf() {
+							return
+						}
+						if d := n.Left().Height() - n.Right().Height(); d < -1 || d > 1 {
+							t.Errorf("node %v has children with heights %v and %v", n.ID(), n.Left().Height(), n.Right().Height())
+						}
+					})
+				}
+				for i := 0; i < 10; i++ {
+					q := perf.GenerateAABB(k, 100, 200)
+					if diff := cmp.Diff(
+						tbf.BroadPhase(q), tbvh.BroadPhase(q),
+						cmpopts.SortSlices(func(a, b id.ID) bool { return a < b }),
+					); diff != "" {
+						t.Errorf("BroadPhase() mismatch (-want +got):\n%v", diff)
+					}
+				}
+			}
+
+			if errs := tbvh.InsertBatch(data); errs != nil {
+				t.Fatalf("InsertBatch() = %v, want = nil", errs)
+			}
+			for x, h := range data {
+				tbf.Insert(x, h)
+			}
+			check(t)
+
+			for i := 0; i < 5; i++ {
+				updates := map[id.ID]hyperrectangle.R{}
+				for x := range data {
+					if x%3 != id.ID(i%3) {
+						updates[x] = perf.GenerateAABB(k, 100, 200)
+					}
+				}
+				if errs := tbvh.UpdateBatch(updates); errs != nil {
+					t.Fatalf("UpdateBatch() = %v, want = nil", errs)
+				}
+				for x, h := range updates {
+					tbf.Update(x, h)
+				}
+				check(t)
+			}
+
+			var xs []id.ID
+			for x := range data {
+				if x%2 == 0 {
+					xs = append(xs, x)
+				}
+			}
+			if errs := tbvh.RemoveBatch(xs); errs != nil {
+				t.Fatalf("RemoveBatch() = %v, want = nil", errs)
+			}
+			for _, x := range xs {
+				tbf.Remove(x)
+			}
+			check(t)
+
+			// The tree supports the usual mutations after a batch.
+			for x := range data {
+				if x%2 == 0 {
+					tbvh.Insert(x, data[x])
+					tbf.Insert(x, data[x])
+				}
+			}
+			check(t)
+
+			xs = tbvh.IDs()
+			if errs := tbvh.RemoveBatch(xs); errs != nil {
+				t.Fatalf("RemoveBatch() = %v, want = nil", errs)
+			}
+			if got := tbvh.Cursor(); got.IsValid() {
+				t.Errorf("Cursor().IsValid() = true, want = false")
+			}
+		})
+	}
+
+	t.Run("Errors", func(t *testing.T) {
+		tbvh := New(O{
+			K:         k,
+			LeafSize:  1,
+			Tolerance: 1,
+		})
+		h := *hyperrectangle.New(vector.V{0, 0, 0}, vector.V{1, 1, 1})
+		tbvh.Insert(0, h)
+
+		errs := tbvh.InsertBatch(map[id.ID]hyperrectangle.R{0: h, 1: h})
+		if _, ok := errs[0]; !ok || len(errs) != 1 {
+			t.Errorf("InsertBatch() = %v, want a single error for object 0", errs)
+		}
+		errs = tbvh.UpdateBatch(map[id.ID]hyperrectangle.R{1: h, 2: h})
+		if _, ok := errs[2]; !ok || len(errs) != 1 {
+			t.Errorf("UpdateBatch() = %v, want a single error for object 2", errs)
+		}
+		errs = tbvh.RemoveBatch([]id.ID{0, 0, 3})
+		if _, ok := errs[3]; !ok || len(errs) != 2 {
+			t.Errorf("RemoveBatch() = %v, want errors for objects 0 and 3", errs)
+		}
+		if err := tbvh.Validate(); err != nil {
+			t.Fatalf("Validate() = %v, want = nil", err)
+		}
+		if got, want := tbvh.IDs(), []id.ID{1}; !cmp.Equal(got, want, cmpopts.SortSlices(func(a, b id.ID) bool { return a < b })) {
+			t.Errorf("IDs() = %v, want = %v", got, want)
+		}
+	})
+}
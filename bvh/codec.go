@@ -0,0 +1,266 @@
+package bvh
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-bvh/internal/cache"
+	"github.com/downflux/go-bvh/internal/cache/node"
+	"github.com/downflux/go-bvh/internal/codec"
+	"github.com/downflux/go-geometry/nd/hyperrectangle"
+	"github.com/downflux/go-geometry/nd/vector"
+
+	cid "github.com/downflux/go-bvh/internal/cache/id"
+)
+
+const (
+	magic = "GBVH"
+
+	// version is the current binary format version. The version must be
+	// incremented on any change to the binary layout.
+	version uint64 = 1
+)
+
+// MarshalBinary encodes the tree into a versioned binary format. The encoded
+// tree includes the tree options, the full node layout (including the fat node
+// AABBs and cached node heights and heuristics), and the object AABBs.
+//
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (t *T) MarshalBinary() ([]byte, error) {
+	w := codec.NewW(1024)
+
+	w.Raw([]byte(magic))
+	w.Uint64(version)
+
+	w.Uint64(uint64(t.o.K))
+	w.Uint64(uint64(t.o.LeafSize))
+	w.Float64(t.o.Tolerance)
+	for _, x := range []int{
+		int(t.o.Build),
+		int(t.o.Candidate),
+		int(t.o.Split),
+		int(t.o.Balance),
+		int(t.o.Heuristic),
+	} {
+		w.Int64(int64(x))
+	}
+	w.Bool(t.o.Deterministic)
+	w.Float64(t.o.Margin)
+
+	w.Int64(int64(t.root))
+	t.c.Encode(w)
+
+	xs := make([]id.ID, 0, len(t.data))
+	for x := range t.data {
+		xs = append(xs, x)
+	}
+	sort.Slice(xs, func(i, j int) bool { return xs[i] < xs[j] })
+
+	w.Uint64(uint64(len(xs)))
+	for _, x := range xs {
+		w.Uint64(uint64(x))
+		w.Float64s(t.data[x].Min())
+		w.Float64s(t.data[x].Max())
+
+		w.Bool(t.hasBounds(x))
+		if t.hasBounds(x) {
+			w.Float64s(t.bounds[x].Min())
+			w.Float64s(t.bounds[x].Max())
+		}
+
+		o := t.objects[x]
+		w.Bool(o.Margin != nil)
+		if o.Margin != nil {
+			w.Float64(*o.Margin)
+		}
+		w.Bool(o.Static)
+	}
+
+	return w.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a tree previously encoded by MarshalBinary, and
+// replaces the contents of t with the decoded tree. The decoded tree is checked
+// against the invariants in Validate, and so UnmarshalBinary may be safely
+// called on untrusted input; t is not modified if an error is returned.
+//
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (t *T) UnmarshalBinary(data []byte) error {
+	r := codec.NewR(data)
+
+	if m := r.Raw(len(magic)); r.Err() != nil || string(m) != magic {
+		return fmt.Errorf("cannot decode tree: invalid file header")
+	}
+	v := r.Uint64()
+	if v != version {
+		return fmt.Errorf("cannot decode tree: unsupported version %v", v)
+	}
+
+	o := O{
+		K:         vector.D(r.Uint64()),
+		LeafSize:  int(r.Uint64()),
+		Tolerance: r.Float64(),
+		Build:     Build(r.Int64()),
+		Candidate: Candidate(r.Int64()),
+		Split:     Split(r.Int64()),
+		Balance:   Balance(r.Int64()),
+		Heuristic: Heuristic(r.Int64()),
+
+		Deterministic: r.Bool(),
+		Margin:        r.Float64(),
+	}
+	if r.Err() != nil {
+		return fmt.Errorf("cannot decode tree options: %v", r.Err())
+	}
+	if err := o.validate(); err != nil {
+		return fmt.Errorf("cannot decode tree options: %v", err)
+	}
+
+	u := New(o)
+
+	u.root = cid.ID(r.Int64())
+	u.c = cache.Decode(r, o.Heuristic.heuristic())
+	if r.Err() != nil {
+		return fmt.Errorf("cannot decode tree nodes: %v", r.Err())
+	}
+	if u.c.K() != o.K || u.c.LeafSize() != o.LeafSize {
+		return fmt.Errorf("cannot decode tree nodes: mismatching node dimensions")
+	}
+
+	// Each object encodes its ID and AABB in at least 8 * (1 + 2K) bytes.
+	// Check the dimension first to avoid overflowing the object size.
+	size := r.Len() + 1
+	if uint64(o.K) <= uint64(r.Len())/16 {
+		size = 8 * (1 + 2*int(o.K))
+	}
+	for i, n := 0, r.Count(size); i < n; i++ {
+		x := id.ID(r.Uint64())
+		aabb := *hyperrectangle.New(
+			vector.V(make([]float64, o.K)),
+			vector.V(make([]float64, o.K)),
+		)
+		r.Float64s(aabb.Min())
+		r.Float64s(aabb.Max())
+		u.data[x] = aabb
+		u.bounds[x] = aabb
+
+		if r.Bool() {
+			bounds := *hyperrectangle.New(
+				vector.V(make([]float64, o.K)),
+				vector.V(make([]float64, o.K)),
+			)
+			r.Float64s(bounds.Min())
+			r.Float64s(bounds.Max())
+			if r.Err() == nil && !hyperrectangle.Contains(bounds, aabb) {
+				return fmt.Errorf("cannot decode tree data: object %v bounds do not contain the object", x)
+			}
+			u.bounds[x] = bounds
+		}
+
+		var p InsertO
+		if r.Bool() {
+			m := r.Float64()
+			p.Margin = &m
+		}
+		p.Static = r.Bool()
+		if r.Err() == nil {
+			if err := p.validate(); err != nil {
+				return fmt.Errorf("cannot decode tree data: object %v: %v", x, err)
+			}
+		}
+		if p != (InsertO{}) {
+			u.objects[x] = p
+		}
+		if p.Static {
+			u.static[x] = struct{}{}
+		}
+	}
+	if r.Err() != nil {
+		return fmt.Errorf("cannot decode tree data: %v", r.Err())
+	}
+	if r.Len() > 0 {
+		return fmt.Errorf("cannot decode tree: %v trailing bytes", r.Len())
+	}
+
+	if u.root != cid.IDInvalid {
+		root, ok := u.c.Get(u.root)
+		if !ok {
+			return fmt.Errorf("cannot decode tree: invalid root node %v", u.root)
+		}
+		// The input may be arbitrarily corrupted, and so the tree
+		// is only checked with iterative traversals, which cannot
+		// overflow the stack on e.g. a cyclic tree.
+		if err := u.checkTree(root); err != nil {
+			return fmt.Errorf("cannot decode tree: %v", err)
+		}
+		if err := u.Validate(); err != nil {
+			return fmt.Errorf("cannot decode tree: %v", err)
+		}
+	}
+	if len(u.nodes) != len(u.data) {
+		return fmt.Errorf("cannot decode tree: mismatching tree objects and data")
+	}
+
+	*t = *u
+	return nil
+}
+
+// checkTree iteratively checks that the nodes reachable from the input root
+// form a well-formed tree, and populates the object to leaf node lookup table.
+// The remaining tree invariants are checked by Validate.
+//
+// Each node link must refer to an allocated node, internal nodes must have
+// exactly two children and no objects, the parent and child links must agree,
+// and no node may be reachable via multiple paths. Each object must be held by
+// exactly one leaf node.
+func (t *T) checkTree(root node.N) error {
+	type e struct {
+		x cid.ID
+		p cid.ID
+	}
+
+	visited := map[cid.ID]bool{}
+	open := []e{{x: root.ID(), p: cid.IDInvalid}}
+
+	var s e
+	for len(open) > 0 {
+		s, open = open[len(open)-1], open[:len(open)-1]
+
+		n, ok := t.c.Get(s.x)
+		if !ok {
+			return fmt.Errorf("node %v links to freed node %v", s.p, s.x)
+		}
+		if visited[s.x] {
+			return fmt.Errorf("node %v is reachable via multiple paths", s.x)
+		}
+		visited[s.x] = true
+
+		p, l, r, _ := t.c.Links(s.x)
+		if p != s.p {
+			return fmt.Errorf("node %v has parent %v, but is a child of %v", s.x, p, s.p)
+		}
+		if l.IsValid() != r.IsValid() {
+			return fmt.Errorf("node %v has a single child (left = %v, right = %v)", s.x, l, r)
+		}
+
+		if l.IsValid() {
+			if len(n.Leaves()) > 0 {
+				return fmt.Errorf("internal node %v holds objects", s.x)
+			}
+			open = append(open, e{x: r, p: s.x}, e{x: l, p: s.x})
+			continue
+		}
+
+		for x := range n.Leaves() {
+			if _, ok := t.data[x]; !ok {
+				return fmt.Errorf("leaf node %v holds unknown object %v", s.x, x)
+			}
+			if _, ok := t.nodes[x]; ok {
+				return fmt.Errorf("object %v is held by multiple leaf nodes", x)
+			}
+			t.nodes[x] = s.x
+		}
+	}
+	return nil
+}
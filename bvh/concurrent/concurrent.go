@@ -0,0 +1,190 @@
+// Package concurrent provides a thread-safe wrapper around a BVH tree.
+//
+// Readers (e.g. BroadPhase, Raycast) may run in parallel with one another, but
+// are serialized against writers (e.g. Insert, Update, Remove). Writers which
+// apply many mutations at once, e.g. once per simulation tick, should use the
+// batched Write call to only acquire the lock once.
+package concurrent
+
+import (
+	"sync"
+
+	"github.com/downflux/go-bvh/bvh"
+	"github.com/downflux/go-bvh/bvh/op/query"
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-geometry/nd/hyperrectangle"
+	"github.com/downflux/go-geometry/nd/ray"
+	"github.com/downflux/go-geometry/nd/vector"
+)
+
+type T struct {
+	l sync.RWMutex
+	t *bvh.T
+}
+
+func New(o bvh.O) *T { return &T{t: bvh.New(o)} }
+
+// NewFromData constructs a tree via bvh.NewFromData.
+func NewFromData(o bvh.O, data map[id.ID]hyperrectangle.R) *T {
+	return &T{t: bvh.NewFromData(o, data)}
+}
+
+func (t *T) Insert(x id.ID, aabb hyperrectangle.R) error {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	return t.t.Insert(x, aabb)
+}
+
+func (t *T) InsertWithOptions(x id.ID, aabb hyperrectangle.R, o bvh.InsertO) error {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	return t.t.InsertWithOptions(x, aabb, o)
+}
+
+func (t *T) Update(x id.ID, aabb hyperrectangle.R) error {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	return t.t.Update(x, aabb)
+}
+
+func (t *T) Move(x id.ID, aabb hyperrectangle.R, d vector.V) error {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	return t.t.Move(x, aabb, d)
+}
+
+func (t *T) Refit(updates map[id.ID]hyperrectangle.R) error {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	return t.t.Refit(updates)
+}
+
+func (t *T) Remove(x id.ID) error {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	return t.t.Remove(x)
+}
+
+func (t *T) Optimize(b bvh.Budget) int {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	return t.t.Optimize(b)
+}
+
+func (t *T) InsertBatch(data map[id.ID]hyperrectangle.R) map[id.ID]error {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	return t.t.InsertBatch(data)
+}
+
+func (t *T) UpdateBatch(data map[id.ID]hyperrectangle.R) map[id.ID]error {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	return t.t.UpdateBatch(data)
+}
+
+func (t *T) RemoveBatch(xs []id.ID) map[id.ID]error {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	return t.t.RemoveBatch(xs)
+}
+
+// Write runs f while holding the write lock, which allows the caller to apply
+// an arbitrary batch of mutations to the underlying tree atomically with
+// respect to readers. Write returns the error returned by f.
+//
+// The input tree is only valid for the duration of f, and must not be retained
+// by the caller.
+func (t *T) Write(f func(t *bvh.T) error) error {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	return f(t.t)
+}
+
+// Read runs f while holding the read lock, which allows the caller to run
+// several queries against a consistent view of the tree.
+//
+// The function f must not mutate the tree, and must not call the
+// callback-style queries (e.g. BroadPhaseFunc) of the underlying tree, as these
+// share an internal buffer. As with Write, the input tree must not be retained
+// by the caller.
+func (t *T) Read(f func(t *bvh.T)) {
+	t.l.RLock()
+	defer t.l.RUnlock()
+
+	f(t.t)
+}
+
+func (t *T) Degradation() float64 {
+	t.l.RLock()
+	defer t.l.RUnlock()
+
+	return t.t.Degradation()
+}
+
+func (t *T) IDs() []id.ID {
+	t.l.RLock()
+	defer t.l.RUnlock()
+
+	return t.t.IDs()
+}
+
+func (t *T) BroadPhase(q hyperrectangle.R) []id.ID {
+	t.l.RLock()
+	defer t.l.RUnlock()
+
+	return t.t.BroadPhase(q)
+}
+
+func (t *T) Raycast(q ray.R) []id.ID {
+	t.l.RLock()
+	defer t.l.RUnlock()
+
+	return t.t.Raycast(q)
+}
+
+func (t *T) Query(f func(r hyperrectangle.R) bool) []id.ID {
+	t.l.RLock()
+	defer t.l.RUnlock()
+
+	return t.t.Query(f)
+}
+
+func (t *T) KNN(p vector.V, k int) []id.ID {
+	t.l.RLock()
+	defer t.l.RUnlock()
+
+	return t.t.KNN(p, k)
+}
+
+func (t *T) RaycastFirst(q ray.R, max float64) (query.Hit, bool) {
+	t.l.RLock()
+	defer t.l.RUnlock()
+
+	return t.t.RaycastFirst(q, max)
+}
+
+func (t *T) RaycastSorted(q ray.R, max float64) []query.Hit {
+	t.l.RLock()
+	defer t.l.RUnlock()
+
+	return t.t.RaycastSorted(q, max)
+}
+
+func (t *T) Pairs() []query.P {
+	t.l.RLock()
+	defer t.l.RUnlock()
+
+	return t.t.Pairs()
+}
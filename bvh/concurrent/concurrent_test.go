@@ -0,0 +1,124 @@
+package concurrent
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/downflux/go-bvh/bvh"
+	"github.com/downflux/go-bvh/container"
+	"github.com/downflux/go-bvh/container/bruteforce"
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-bvh/perf"
+	"github.com/downflux/go-geometry/nd/hyperrectangle"
+	"github.com/downflux/go-geometry/nd/ray"
+	"github.com/downflux/go-geometry/nd/vector"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+var (
+	_ container.C = &T{}
+)
+
+// TestStress runs concurrent readers against a writer which mutates the tree
+// every tick. This test should be run with the -race flag.
+func TestStress(t *testing.T) {
+	const (
+		k       = 3
+		n       = 500
+		ticks   = 50
+		readers = 8
+	)
+
+	o := bvh.O{
+		K:         k,
+		LeafSize:  4,
+		Tolerance: 1.05,
+	}
+
+	data := perf.GenerateRandomBoxes(n, k, 100, 200)
+	tbvh := NewFromData(o, data)
+
+	done := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				q := perf.GenerateAABB(k, 50, 70)
+				switch i % 4 {
+				case 0:
+					tbvh.BroadPhase(q)
+				case 1:
+					tbvh.Raycast(*ray.New(q.Min(), vector.V{1, 1, 1}))
+				case 2:
+					tbvh.KNN(q.Min(), 10)
+				case 3:
+					tbvh.Read(func(t *bvh.T) {
+						// Ensure a consistent view across
+						// multiple queries.
+						if got, want := len(t.BroadPhase(q)), len(t.BroadPhase(q)); got != want {
+							panic("inconsistent read")
+						}
+					})
+				}
+			}
+		}(i)
+	}
+
+	tbf := bruteforce.New()
+	for x, h := range data {
+		tbf.Insert(x, h)
+	}
+
+	for i := 0; i < ticks; i++ {
+		// Move a random subset of objects in a single batch.
+		moves := map[id.ID]hyperrectangle.R{}
+		for j := 0; j < n/10; j++ {
+			moves[id.ID(rand.Intn(n))] = perf.GenerateAABB(k, 100, 200)
+		}
+
+		if err := tbvh.Write(func(t *bvh.T) error {
+			for x, h := range moves {
+				if err := t.Update(x, h); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			t.Fatalf("Write() = %v, want = nil", err)
+		}
+		for x, h := range moves {
+			tbf.Update(x, h)
+		}
+
+		// Interleave unbatched writes.
+		x := id.ID(n + i)
+		h := perf.GenerateAABB(k, 100, 200)
+		tbvh.Insert(x, h)
+		tbf.Insert(x, h)
+	}
+
+	close(done)
+	wg.Wait()
+
+	q := perf.GenerateAABB(k, 100, 200)
+	if diff := cmp.Diff(
+		tbf.BroadPhase(q), tbvh.BroadPhase(q),
+		cmpopts.SortSlices(func(a, b id.ID) bool { return a < b }),
+	); diff != "" {
+		t.Errorf("BroadPhase() mismatch (-want +got):\n%v", diff)
+	}
+	if err := tbvh.Write(func(t *bvh.T) error { return t.Validate() }); err != nil {
+		t.Errorf("Validate() = %v, want = nil", err)
+	}
+}
@@ -0,0 +1,65 @@
+// Package cursor provides a read-only view into the nodes of a BVH tree. This
+// allows users to implement custom traversal algorithms (e.g. ordered or
+// aggregate queries) without access to the internal tree representation.
+package cursor
+
+import (
+	"sort"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-bvh/internal/cache/node"
+	"github.com/downflux/go-geometry/nd/hyperrectangle"
+)
+
+// C is a read-only cursor pointing at a single BVH node. The zero value is an
+// invalid cursor, which is e.g. returned as the parent of the root node.
+//
+// A cursor is only valid until the next mutation of the underlying tree.
+type C struct {
+	n node.N
+}
+
+// New creates a cursor pointing at the input node. A nil input node will
+// generate an invalid cursor.
+func New(n node.N) C { return C{n: n} }
+
+// IsValid checks if the cursor points at an existing node.
+func (c C) IsValid() bool { return c.n != nil }
+
+// AABB returns the (buffered) bounding box of the node. All objects within
+// the subtree rooted at this node are contained in this AABB. The returned
+// AABB is owned by the tree and must not be mutated.
+func (c C) AABB() hyperrectangle.R { return c.n.AABB().R() }
+
+// Height returns the height of the node, where leaf nodes have a height of
+// zero.
+func (c C) Height() int { return c.n.Height() }
+
+// Heuristic returns the cached node heuristic of the node AABB.
+func (c C) Heuristic() float64 { return c.n.Heuristic() }
+
+func (c C) IsLeaf() bool { return c.n.IsLeaf() }
+func (c C) IsRoot() bool { return c.n.IsRoot() }
+
+// Left returns the left child of the node. If the node is a leaf, the
+// returned cursor is invalid.
+func (c C) Left() C { return New(c.n.Left()) }
+
+// Right returns the right child of the node. If the node is a leaf, the
+// returned cursor is invalid.
+func (c C) Right() C { return New(c.n.Right()) }
+
+// Parent returns the parent of the node. If the node is the root, the returned
+// cursor is invalid.
+func (c C) Parent() C { return New(c.n.Parent()) }
+
+// Leaves returns a copy of the list of objects tracked by the node, sorted by
+// object ID. Internal nodes do not track any objects.
+func (c C) Leaves() []id.ID {
+	xs := make([]id.ID, 0, len(c.n.Leaves()))
+	for x := range c.n.Leaves() {
+		xs = append(xs, x)
+	}
+	sort.Slice(xs, func(i, j int) bool { return xs[i] < xs[j] })
+	return xs
+}
@@ -0,0 +1,20 @@
+// Package build constructs a BVH tree from a complete set of objects at once.
+// Bulk building is significantly faster than inserting each object
+// individually, and generally produces higher quality trees.
+package build
+
+import (
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-bvh/internal/cache"
+	"github.com/downflux/go-bvh/internal/cache/node"
+	"github.com/downflux/go-geometry/nd/hyperrectangle"
+)
+
+// B builds a tree in the input (empty) cache which contains all objects in the
+// input data map, and returns the root of the new tree. If there is no input
+// data, B returns nil.
+//
+// The returned tree has valid AABB, height, and heuristic caches, where the
+// leaf AABBs are buffered by the input tolerance factor, except for leaves
+// which only contain objects in the input static set, as per node.SetAABB.
+type B func(c *cache.C, data map[id.ID]hyperrectangle.R, static map[id.ID]struct{}, tolerance float64) node.N
@@ -0,0 +1,190 @@
+package build
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-bvh/internal/cache"
+	"github.com/downflux/go-bvh/internal/cache/node"
+	"github.com/downflux/go-bvh/internal/cache/node/util"
+	"github.com/downflux/go-bvh/perf"
+	"github.com/downflux/go-geometry/nd/hyperrectangle"
+	"github.com/downflux/go-geometry/nd/vector"
+	"github.com/google/go-cmp/cmp"
+)
+
+var (
+	builders = map[string]B{
+		"SAH":                SAH,
+		"Morton":             Morton,
+		"SAHOptimized":       Optimized(SAH),
+		"MortonOptimized":    Optimized(Morton),
+		"SAHRestructured":    Restructured(SAH),
+		"MortonRestructured": Restructured(Morton),
+	}
+)
+
+func TestB(t *testing.T) {
+	type config struct {
+		name string
+		k    vector.D
+		size int
+		data map[id.ID]hyperrectangle.R
+	}
+
+	configs := []config{
+		{
+			name: "Empty",
+			k:    2,
+			size: 1,
+			data: map[id.ID]hyperrectangle.R{},
+		},
+		{
+			name: "Single",
+			k:    2,
+			size: 1,
+			data: map[id.ID]hyperrectangle.R{
+				100: *hyperrectangle.New(vector.V{0, 0}, vector.V{1, 1}),
+			},
+		},
+		{
+			name: "Coincident",
+			k:    2,
+			size: 2,
+			data: map[id.ID]hyperrectangle.R{
+				100: *hyperrectangle.New(vector.V{0, 0}, vector.V{1, 1}),
+				101: *hyperrectangle.New(vector.V{0, 0}, vector.V{1, 1}),
+				102: *hyperrectangle.New(vector.V{0, 0}, vector.V{1, 1}),
+				103: *hyperrectangle.New(vector.V{0, 0}, vector.V{1, 1}),
+				104: *hyperrectangle.New(vector.V{0, 0}, vector.V{1, 1}),
+			},
+		},
+	}
+	for _, k := range []vector.D{1, 2, 3, 25, 70} {
+		for _, size := range []int{1, 4} {
+			configs = append(configs, config{
+				name: fmt.Sprintf("Random/K=%v/LeafSize=%v", k, size),
+				k:    k,
+				size: size,
+				data: perf.GenerateRandomBoxes(int(1e4/k), k, 100, 200),
+			})
+		}
+	}
+	for _, k := range []vector.D{2, 3} {
+		for _, size := range []int{1, 4} {
+			configs = append(configs, config{
+				name: fmt.Sprintf("Tiles/K=%v/LeafSize=%v", k, size),
+				k:    k,
+				size: size,
+				data: perf.GenerateRandomTiles(1000, k),
+			})
+		}
+	}
+
+	for _, c := range configs {
+		for name, b := range builders {
+			t.Run(fmt.Sprintf("%v/%v", name, c.name), func(t *testing.T) {
+				ch := cache.New(cache.O{
+					K:        c.k,
+					LeafSize: c.size,
+				})
+
+				root := b(ch, c.data, nil, 1.05)
+				if len(c.data) == 0 {
+					if root != nil {
+						t.Fatalf("B() = %v, want = nil", root.ID())
+					}
+					return
+				}
+
+				if err := util.Validate(ch, c.data, root); err != nil {
+					t.Fatalf("Validate() = %v, want = nil", err)
+				}
+
+				got := map[id.ID]bool{}
+				util.PreOrder(root, func(n node.N) {
+					for x := range n.Leaves() {
+						if got[x] {
+							t.Errorf("object %v found in multiple leaves", x)
+						}
+						got[x] = true
+					}
+				})
+				for x := range c.data {
+					if !got[x] {
+						t.Errorf("object %v not found in the tree", x)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestMorton(t *testing.T) {
+	const n = 1000
+
+	// Objects along a line are sorted by their Morton code, and so should
+	// appear in order in the leaves of the tree.
+	data := make(map[id.ID]hyperrectangle.R, n)
+	for i := 0; i < n; i++ {
+		data[id.ID(i)] = *hyperrectangle.New(vector.V{float64(i)}, vector.V{float64(i) + 0.5})
+	}
+
+	c := cache.New(cache.O{
+		K:        1,
+		LeafSize: 1,
+	})
+	root := Morton(c, data, nil, 1)
+	if err := util.Validate(c, data, root); err != nil {
+		t.Fatalf("Validate() = %v, want = nil", err)
+	}
+
+	var got []id.ID
+	var f func(n node.N)
+	f = func(n node.N) {
+		if n.IsLeaf() {
+			for x := range n.Leaves() {
+				got = append(got, x)
+			}
+			return
+		}
+		f(n.Left())
+		f(n.Right())
+	}
+	f(root)
+
+	want := make([]id.ID, 0, n)
+	for i := 0; i < n; i++ {
+		want = append(want, id.ID(i))
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Morton() mismatch (-want +got):\n%v", diff)
+	}
+}
+
+func TestRadix(t *testing.T) {
+	for _, n := range []int{0, 1, 1000} {
+		t.Run(fmt.Sprintf("N=%v", n), func(t *testing.T) {
+			codes := make([]code, 0, n)
+			for i := 0; i < n; i++ {
+				// Ensure there are duplicate codes, whose order
+				// depends on the object IDs.
+				codes = append(codes, code{x: id.ID(rand.Uint64() >> rand.Intn(64)), m: rand.Uint64() >> (rand.Intn(8) * 8)})
+			}
+
+			want := make([]code, len(codes))
+			copy(want, codes)
+			sort.Slice(want, func(i, j int) bool {
+				return want[i].m < want[j].m || (want[i].m == want[j].m && want[i].x < want[j].x)
+			})
+
+			radix(codes)
+			if diff := cmp.Diff(want, codes, cmp.AllowUnexported(code{})); diff != "" {
+				t.Errorf("radix() mismatch (-want +got):\n%v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package build
+
+import (
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-bvh/internal/cache"
+	"github.com/downflux/go-bvh/internal/cache/node"
+	"github.com/downflux/go-bvh/internal/cache/node/util"
+	"github.com/downflux/go-bvh/internal/cache/op/balance"
+	"github.com/downflux/go-geometry/nd/hyperrectangle"
+)
+
+// Optimized returns a builder which runs a fast treelet optimization pass over
+// the tree generated by the input builder b.
+//
+// This is a Restructured pass which only considers treelets of (up to) four
+// leaves, e.g. the children and grandchildren of each internal node.
+func Optimized(b B) B { return restructured(b, 4) }
+
+// Restructured returns a builder which runs a full treelet restructuring pass
+// over the tree generated by the input builder b, as per Karras and Aila 2013.
+//
+// Each internal node is visited in post-order, and the treelet rooted at the
+// node is restructured into its optimal topology via balance.Treelet. This is
+// significantly slower than the Optimized pass, but considers much larger
+// treelets. Unlike the balance.Rotate op, the resultant tree is not guaranteed
+// to remain height-balanced.
+func Restructured(b B) B { return restructured(b, 7) }
+
+func restructured(b B, size int) B {
+	return func(c *cache.C, data map[id.ID]hyperrectangle.R, static map[id.ID]struct{}, tolerance float64) node.N {
+		root := b(c, data, static, tolerance)
+		if root == nil {
+			return nil
+		}
+
+		f := balance.Treelet(size)
+		util.PostOrder(root, func(n node.N) {
+			if !n.IsLeaf() {
+				f(n)
+				node.SetHeight(n)
+			}
+		})
+		return root
+	}
+}
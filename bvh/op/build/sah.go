@@ -0,0 +1,221 @@
+package build
+
+import (
+	"math"
+	"sort"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-bvh/internal/cache"
+	"github.com/downflux/go-bvh/internal/cache/node"
+	"github.com/downflux/go-geometry/nd/hyperrectangle"
+	"github.com/downflux/go-geometry/nd/vector"
+
+	cid "github.com/downflux/go-bvh/internal/cache/id"
+)
+
+const (
+	// nBins is the number of candidate split buckets per axis. Wald 2007
+	// notes the resultant tree quality is close to that of a full sweep
+	// with as few as 16 bins.
+	nBins = 16
+)
+
+var (
+	_ B = SAH
+)
+
+// SAH constructs a tree top-down by recursively partitioning the input objects
+// with the binned SAH split as described in Wald 2007.
+//
+// At each internal node, the object centroids are binned along each axis, and
+// the partition plane between bins with the lowest estimated cost
+//
+//	SA(L) * |L| + SA(R) * |R|
+//
+// is selected. Subsets of at most c.LeafSize() objects become leaf nodes.
+//
+// N.B.: Unlike the incremental insert operation, SAH does not guarantee that the
+// resultant tree is height-balanced.
+func SAH(c *cache.C, data map[id.ID]hyperrectangle.R, static map[id.ID]struct{}, tolerance float64) node.N {
+	if len(data) == 0 {
+		return nil
+	}
+
+	xs := make([]id.ID, 0, len(data))
+	for x := range data {
+		xs = append(xs, x)
+	}
+
+	// Sort the input to ensure the output tree does not depend on the map
+	// iteration order.
+	sort.Slice(xs, func(i, j int) bool { return xs[i] < xs[j] })
+
+	return sah(c, data, static, tolerance, newBinner(c.K()), cid.IDInvalid, xs)
+}
+
+func sah(c *cache.C, data map[id.ID]hyperrectangle.R, static map[id.ID]struct{}, tolerance float64, b *binner, p cid.ID, xs []id.ID) node.N {
+	n := c.GetOrDie(c.Insert(p, cid.IDInvalid, cid.IDInvalid, false))
+
+	if len(xs) <= c.LeafSize() {
+		for _, x := range xs {
+			n.Leaves()[x] = struct{}{}
+		}
+		node.SetAABB(n, data, static, tolerance)
+		node.SetHeight(n)
+		return n
+	}
+
+	i := b.partition(c, data, xs)
+
+	n.SetLeft(sah(c, data, static, tolerance, b, n.ID(), xs[:i]).ID())
+	n.SetRight(sah(c, data, static, tolerance, b, n.ID(), xs[i:]).ID())
+
+	node.SetAABB(n, nil, nil, 1)
+	node.SetHeight(n)
+
+	return n
+}
+
+type bin struct {
+	n    int
+	aabb hyperrectangle.M
+}
+
+// binner holds the scratch space used to calculate the binned SAH split. The
+// buffers are reused across all partition calls.
+type binner struct {
+	k    vector.D
+	bins [nBins]bin
+
+	// cost[i] tracks the estimated cost of the objects in bins [0, i).
+	cost [nBins]float64
+
+	cmin vector.M
+	cmax vector.M
+
+	buf hyperrectangle.M
+}
+
+func newBinner(k vector.D) *binner {
+	b := &binner{
+		k:    k,
+		cmin: vector.V(make([]float64, k)).M(),
+		cmax: vector.V(make([]float64, k)).M(),
+		buf: hyperrectangle.New(
+			vector.V(make([]float64, k)),
+			vector.V(make([]float64, k)),
+		).M(),
+	}
+	for i := range b.bins {
+		b.bins[i].aabb = hyperrectangle.New(
+			vector.V(make([]float64, k)),
+			vector.V(make([]float64, k)),
+		).M()
+	}
+	return b
+}
+
+func centroid(r hyperrectangle.R, i vector.D) float64 { return (r.Min()[i] + r.Max()[i]) / 2 }
+
+func (b *binner) index(data map[id.ID]hyperrectangle.R, x id.ID, i vector.D) int {
+	j := int(nBins * (centroid(data[x], i) - b.cmin[i]) / (b.cmax[i] - b.cmin[i]))
+	if j >= nBins {
+		j = nBins - 1
+	}
+	return j
+}
+
+// partition reorders the input objects in place such that the objects in the
+// left child of the optimal split precede the objects in the right child, and
+// returns the index of the first object in the right child. The returned index
+// is guaranteed to lie in the range (0, len(xs)).
+func (b *binner) partition(c *cache.C, data map[id.ID]hyperrectangle.R, xs []id.ID) int {
+	for i := vector.D(0); i < b.k; i++ {
+		b.cmin[i], b.cmax[i] = math.Inf(1), math.Inf(-1)
+	}
+	for _, x := range xs {
+		for i := vector.D(0); i < b.k; i++ {
+			c := centroid(data[x], i)
+			b.cmin[i] = math.Min(b.cmin[i], c)
+			b.cmax[i] = math.Max(b.cmax[i], c)
+		}
+	}
+
+	opt := math.Inf(1)
+	axis := vector.D(0)
+	split := 0
+
+	for i := vector.D(0); i < b.k; i++ {
+		if b.cmax[i] <= b.cmin[i] {
+			continue
+		}
+
+		for j := range b.bins {
+			b.bins[j].n = 0
+		}
+		for _, x := range xs {
+			j := b.index(data, x, i)
+			if b.bins[j].n == 0 {
+				b.bins[j].aabb.Copy(data[x])
+			} else {
+				b.bins[j].aabb.Union(data[x])
+			}
+			b.bins[j].n++
+		}
+
+		// Sweep from the left to calculate the cost of the left
+		// partition.
+		var n int
+		for j := 1; j < nBins; j++ {
+			if m := b.bins[j-1]; m.n > 0 {
+				if n == 0 {
+					b.buf.Copy(m.aabb.R())
+				} else {
+					b.buf.Union(m.aabb.R())
+				}
+				n += m.n
+			}
+			b.cost[j] = math.Inf(1)
+			if n > 0 {
+				b.cost[j] = c.H(b.buf.R()) * float64(n)
+			}
+		}
+
+		// Sweep from the right and combine with the left partition
+		// costs.
+		n = 0
+		for j := nBins - 1; j > 0; j-- {
+			if m := b.bins[j]; m.n > 0 {
+				if n == 0 {
+					b.buf.Copy(m.aabb.R())
+				} else {
+					b.buf.Union(m.aabb.R())
+				}
+				n += m.n
+			}
+			if n == 0 || n == len(xs) {
+				continue
+			}
+			if cost := b.cost[j] + c.H(b.buf.R())*float64(n); cost < opt {
+				opt = cost
+				axis = i
+				split = j
+			}
+		}
+	}
+
+	// All object centroids coincide, and we cannot separate the objects
+	// spatially. Fall back to splitting the objects evenly.
+	if math.IsInf(opt, 1) {
+		return len(xs) / 2
+	}
+
+	l := 0
+	for r := range xs {
+		if b.index(data, xs[r], axis) < split {
+			xs[l], xs[r] = xs[r], xs[l]
+			l++
+		}
+	}
+	return l
+}
@@ -27,15 +27,78 @@ type O struct {
 	Balance   balance.B
 }
 
-func Insert(c *cache.C, rid cid.ID, data map[id.ID]hyperrectangle.R, x id.ID, tolerance float64) (node.N, []node.N) {
-	return Default.Insert(c, rid, data, x, tolerance)
+func Insert(c *cache.C, rid cid.ID, data map[id.ID]hyperrectangle.R, static map[id.ID]struct{}, x id.ID, tolerance float64) (node.N, []node.N) {
+	return Default.Insert(c, rid, data, static, x, tolerance)
 }
 
 // insert adds a new AABB into a tree, and returns the new root, along with any
 // object node updates.
 //
 // The input data cache is a read-only map within the insert function.
-func (o O) Insert(c *cache.C, rid cid.ID, data map[id.ID]hyperrectangle.R, x id.ID, tolerance float64) (node.N, []node.N) {
+func (o O) Insert(c *cache.C, rid cid.ID, data map[id.ID]hyperrectangle.R, static map[id.ID]struct{}, x id.ID, tolerance float64) (node.N, []node.N) {
+	root, s, mutations := o.leaf(c, rid, data, static, x, tolerance)
+
+	// At this point in execution, all leaf nodes have updated caches. As we
+	// traverse up to the root, we will incrementally rebalance the trees.
+	for m := s; m != nil; m = m.Parent() {
+		if !m.IsLeaf() {
+			node.SetAABB(m, nil, nil, 1)
+			node.SetHeight(m)
+
+			m = o.Balance(m)
+		}
+
+		if m.Parent() == nil {
+			root = m
+		}
+	}
+
+	return root, mutations
+}
+
+// Add adds a new AABB into a tree as per Insert, but only maintains the AVL
+// height constraint of the ancestor nodes, and skips the more expensive
+// rebalancing operations. Ancestor node AABBs are only expanded as necessary to
+// contain the new object, and the traversal up the tree stops as soon as an
+// ancestor is unchanged, which usually happens well before reaching the root.
+//
+// This is useful for adding many objects at once, where the caller is
+// responsible for updating the AABBs of and rebalancing all ancestors of the
+// returned leaf nodes once all objects have been added.
+func (o O) Add(c *cache.C, rid cid.ID, data map[id.ID]hyperrectangle.R, static map[id.ID]struct{}, x id.ID, tolerance float64) (node.N, []node.N) {
+	root, s, mutations := o.leaf(c, rid, data, static, x, tolerance)
+
+	for m := s.Parent(); m != nil; m = m.Parent() {
+		h := m.Height()
+
+		// The parent of s may have been created during the insert,
+		// and therefore may not have a valid AABB; this node will
+		// fail the containment check.
+		expand := !hyperrectangle.Contains(m.AABB().R(), m.Left().AABB().R()) || !hyperrectangle.Contains(m.AABB().R(), m.Right().AABB().R())
+		if expand {
+			node.SetAABB(m, nil, nil, 1)
+		}
+		node.SetHeight(m)
+
+		if !expand && m.Height() == h {
+			break
+		}
+
+		m = balance.AVL(m)
+	}
+
+	for root.Parent() != nil {
+		root = root.Parent()
+	}
+
+	return root, mutations
+}
+
+// leaf adds the input object into a leaf node, splitting the leaf node if
+// necessary, and updates the leaf node caches. leaf returns the (possibly
+// stale) root of the tree, the leaf node from which to update the tree, and
+// the list of mutated leaf nodes.
+func (o O) leaf(c *cache.C, rid cid.ID, data map[id.ID]hyperrectangle.R, static map[id.ID]struct{}, x id.ID, tolerance float64) (node.N, node.N, []node.N) {
 	var mutations []node.N
 
 	root, ok := c.Get(rid)
@@ -59,24 +122,9 @@ func (o O) Insert(c *cache.C, rid cid.ID, data map[id.ID]hyperrectangle.R, x id.
 	}
 
 	for _, n := range mutations {
-		node.SetAABB(n, data, tolerance)
+		node.SetAABB(n, data, static, tolerance)
 		node.SetHeight(n)
 	}
 
-	// At this point in execution, all leaf nodes have updated caches. As we
-	// traverse up to the root, we will incrementally rebalance the trees.
-	for m := s; m != nil; m = m.Parent() {
-		if !m.IsLeaf() {
-			node.SetAABB(m, nil, 1)
-			node.SetHeight(m)
-
-			m = o.Balance(m)
-		}
-
-		if m.Parent() == nil {
-			root = m
-		}
-	}
-
-	return root, mutations
+	return root, s, mutations
 }
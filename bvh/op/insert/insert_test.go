@@ -57,7 +57,7 @@ func TestInsert(t *testing.T) {
 			ng.Leaves()[102] = struct{}{}
 
 			for _, n := range []node.N{ng, nf, nc, nb, na} {
-				node.SetAABB(n, data, 1)
+				node.SetAABB(n, data, nil, 1)
 				node.SetHeight(n)
 			}
 
@@ -93,7 +93,7 @@ func TestInsert(t *testing.T) {
 			wng.Leaves()[100] = struct{}{}
 
 			for _, n := range []node.N{wng, wnf, wne, wnd, wnc, wnb, wna} {
-				node.SetAABB(n, data, 1)
+				node.SetAABB(n, data, nil, 1)
 				node.SetHeight(n)
 			}
 
@@ -192,7 +192,7 @@ func TestInsert(t *testing.T) {
 			wnc.Leaves()[101] = struct{}{}
 
 			for _, n := range []node.N{wnc, wnb, wna} {
-				node.SetAABB(n, data, 1)
+				node.SetAABB(n, data, nil, 1)
 				node.SetHeight(n)
 			}
 
@@ -203,7 +203,7 @@ func TestInsert(t *testing.T) {
 			root := c.GetOrDie(c.Insert(cid.IDInvalid, cid.IDInvalid, cid.IDInvalid, true))
 			root.Leaves()[100] = struct{}{}
 
-			node.SetAABB(root, data, 1)
+			node.SetAABB(root, data, nil, 1)
 			node.SetHeight(root)
 
 			return config{
@@ -225,7 +225,7 @@ func TestInsert(t *testing.T) {
 				LRInvariant: false,
 			}
 
-			got, mutations := Insert(c.c, c.rid, c.data, c.x, c.tolerance)
+			got, mutations := Insert(c.c, c.rid, c.data, nil, c.x, c.tolerance)
 
 			if !f.Equal(got, c.want) {
 				t.Errorf("insert() = %v, _, want = %v, _", got, c.want)
@@ -0,0 +1,162 @@
+// Package optimize implements the insertion-based tree optimization described
+// in Bittner et al. 2013.
+package optimize
+
+import (
+	"math"
+	"sort"
+
+	"github.com/downflux/go-bvh/internal/cache"
+	"github.com/downflux/go-bvh/internal/cache/node"
+	"github.com/downflux/go-bvh/internal/cache/node/util"
+	"github.com/downflux/go-bvh/internal/cache/op/candidate"
+
+	cid "github.com/downflux/go-bvh/internal/cache/id"
+)
+
+// Optimize incrementally improves the quality of an existing tree. Internal
+// nodes are visited in decreasing order of inefficiency; each visited node is
+// removed from the tree along with its parent, and its two child subtrees are
+// reinserted at the positions which minimize the total tree cost, as found by
+// the Bittner et al. 2013 branch and bound search.
+//
+// The budget function is called before each node is visited, and the
+// optimization stops once the budget returns false. Each internal node is
+// visited at most once per call.
+//
+// Leaf nodes are not modified, and so any external object to leaf node lookup
+// remains valid. As with the treelet optimization in the build package, the
+// resultant tree is not guaranteed to remain height-balanced.
+//
+// Optimize returns the new root of the tree, along with the number of visited
+// nodes.
+func Optimize(c *cache.C, rid cid.ID, budget func() bool) (node.N, int) {
+	root, ok := c.Get(rid)
+	if !ok {
+		return nil, 0
+	}
+
+	type entry struct {
+		x cid.ID
+		m float64
+	}
+
+	var candidates []entry
+	util.PreOrder(root, func(n node.N) {
+		if !n.IsLeaf() && !n.IsRoot() {
+			candidates = append(candidates, entry{
+				x: n.ID(),
+				m: inefficiency(n),
+			})
+		}
+	})
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].m != candidates[j].m {
+			return candidates[i].m > candidates[j].m
+		}
+		return candidates[i].x < candidates[j].x
+	})
+
+	var k int
+	for _, m := range candidates {
+		// Earlier reinsertions may have freed the node, or reused the
+		// node ID for a different node.
+		n, ok := c.Get(m.x)
+		if !ok || n.IsLeaf() || n.IsRoot() {
+			continue
+		}
+
+		if !budget() {
+			break
+		}
+
+		root = reinsert(c, root, n)
+		k++
+	}
+
+	return root, k
+}
+
+// inefficiency calculates the combined inefficiency measure M_sum * M_min *
+// M_max of an internal node, as per Bittner et al. 2013. Nodes which are large
+// relative to their children are more likely to benefit from reinsertion.
+func inefficiency(n node.N) float64 {
+	h := n.Heuristic()
+	l, r := n.Left().Heuristic(), n.Right().Heuristic()
+
+	d := (l + r) / 2 * l * r
+	if d == 0 {
+		if h == 0 {
+			return 0
+		}
+		return math.Inf(1)
+	}
+	return h * h * h / d
+}
+
+// reinsert removes the input internal node n and its parent from the tree, and
+// reinserts the children of n. The input node must not be the root.
+//
+// reinsert returns the new root of the tree.
+func reinsert(c *cache.C, root node.N, n node.N) node.N {
+	l, r := n.Left(), n.Right()
+
+	p := n.Parent()
+	s := p.Child(p.Branch(n.ID()).Sibling())
+
+	if p.IsRoot() {
+		s.SetParent(cid.IDInvalid)
+		root = s
+	} else {
+		q := p.Parent()
+		q.SetChild(q.Branch(p.ID()), s.ID())
+		s.SetParent(q.ID())
+
+		refit(q)
+	}
+
+	c.DeleteOrDie(n.ID())
+	c.DeleteOrDie(p.ID())
+
+	// Reinsert the larger subtree first, as per Bittner et al. 2013.
+	if l.Heuristic() < r.Heuristic() {
+		l, r = r, l
+	}
+	for _, m := range []node.N{l, r} {
+		root = link(c, root, m)
+	}
+
+	return root
+}
+
+// link adds the detached subtree m into the tree as a sibling of the node which
+// minimizes the total tree cost.
+//
+// link returns the new root of the tree.
+func link(c *cache.C, root node.N, m node.N) node.N {
+	s := candidate.BittnerSibling(c, root, m.AABB().R())
+
+	q := c.GetOrDie(c.Insert(cid.IDInvalid, s.ID(), m.ID(), false))
+	if s.IsRoot() {
+		root = q
+	} else {
+		g := s.Parent()
+		g.SetChild(g.Branch(s.ID()), q.ID())
+		q.SetParent(g.ID())
+	}
+	s.SetParent(q.ID())
+	m.SetParent(q.ID())
+
+	refit(q)
+
+	return root
+}
+
+// refit updates the AABBs and heights of the input internal node and all of its
+// ancestors.
+func refit(n node.N) {
+	for m := n; m != nil; m = m.Parent() {
+		node.SetAABB(m, nil, nil, 1)
+		node.SetHeight(m)
+	}
+}
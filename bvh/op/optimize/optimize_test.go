@@ -0,0 +1,154 @@
+package optimize
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/downflux/go-bvh/bvh/op/insert"
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-bvh/internal/cache"
+	"github.com/downflux/go-bvh/internal/cache/node"
+	"github.com/downflux/go-bvh/internal/cache/node/util"
+	"github.com/downflux/go-bvh/internal/cache/node/util/metrics"
+	"github.com/downflux/go-bvh/perf"
+	"github.com/downflux/go-geometry/nd/hyperrectangle"
+	"github.com/downflux/go-geometry/nd/vector"
+
+	cid "github.com/downflux/go-bvh/internal/cache/id"
+)
+
+func TestOptimize(t *testing.T) {
+	type config struct {
+		name string
+		k    vector.D
+		size int
+		data map[id.ID]hyperrectangle.R
+	}
+
+	configs := []config{
+		{
+			name: "Empty",
+			k:    2,
+			size: 1,
+			data: map[id.ID]hyperrectangle.R{},
+		},
+		{
+			name: "Single",
+			k:    2,
+			size: 1,
+			data: map[id.ID]hyperrectangle.R{
+				100: *hyperrectangle.New(vector.V{0, 0}, vector.V{1, 1}),
+			},
+		},
+		{
+			name: "Coincident",
+			k:    2,
+			size: 1,
+			data: map[id.ID]hyperrectangle.R{
+				100: *hyperrectangle.New(vector.V{0, 0}, vector.V{1, 1}),
+				101: *hyperrectangle.New(vector.V{0, 0}, vector.V{1, 1}),
+				102: *hyperrectangle.New(vector.V{0, 0}, vector.V{1, 1}),
+				103: *hyperrectangle.New(vector.V{0, 0}, vector.V{1, 1}),
+				104: *hyperrectangle.New(vector.V{0, 0}, vector.V{1, 1}),
+			},
+		},
+	}
+	for _, k := range []vector.D{2, 3} {
+		for _, size := range []int{1, 4} {
+			configs = append(configs, config{
+				name: fmt.Sprintf("Random/K=%v/LeafSize=%v", k, size),
+				k:    k,
+				size: size,
+				data: perf.GenerateRandomBoxes(1000, k, 100, 200),
+			})
+		}
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			ch := cache.New(cache.O{
+				K:        c.k,
+				LeafSize: c.size,
+			})
+
+			rid := cid.IDInvalid
+			for x := range c.data {
+				root, _ := insert.Insert(ch, rid, c.data, nil, x, 1)
+				rid = root.ID()
+			}
+
+			leaves := map[id.ID]cid.ID{}
+			var before float64
+			if root, ok := ch.Get(rid); ok {
+				util.PreOrder(root, func(n node.N) {
+					for x := range n.Leaves() {
+						leaves[x] = n.ID()
+					}
+				})
+				before = metrics.SAH(root)
+			}
+
+			root, _ := Optimize(ch, rid, func() bool { return true })
+			if len(c.data) == 0 {
+				if root != nil {
+					t.Fatalf("Optimize() = %v, want = nil", root)
+				}
+				return
+			}
+
+			if !root.IsRoot() {
+				t.Fatalf("Optimize() returned a non-root node %v", root.ID())
+			}
+			if err := util.Validate(ch, c.data, root); err != nil {
+				t.Fatalf("Validate() = %v, want = nil", err)
+			}
+
+			got := map[id.ID]cid.ID{}
+			util.PreOrder(root, func(n node.N) {
+				for x := range n.Leaves() {
+					got[x] = n.ID()
+				}
+			})
+			if len(got) != len(leaves) {
+				t.Fatalf("Optimize() returned a tree with %v objects, want = %v", len(got), len(leaves))
+			}
+			for x, n := range leaves {
+				if got[x] != n {
+					t.Errorf("Optimize() moved object %v from leaf %v to %v", x, n, got[x])
+				}
+			}
+
+			if after := metrics.SAH(root); after > before {
+				t.Errorf("SAH() = %v, want <= %v", after, before)
+			}
+		})
+	}
+
+	t.Run("Budget", func(t *testing.T) {
+		data := perf.GenerateRandomBoxes(1000, 2, 100, 200)
+		ch := cache.New(cache.O{
+			K:        2,
+			LeafSize: 1,
+		})
+
+		rid := cid.IDInvalid
+		for x := range data {
+			root, _ := insert.Insert(ch, rid, data, nil, x, 1)
+			rid = root.ID()
+		}
+
+		const want = 10
+
+		var n int
+		root, got := Optimize(ch, rid, func() bool {
+			n++
+			return n <= want
+		})
+		if got != want {
+			t.Errorf("Optimize() = %v, want = %v", got, want)
+		}
+		if err := util.Validate(ch, data, root); err != nil {
+			t.Fatalf("Validate() = %v, want = nil", err)
+		}
+	})
+}
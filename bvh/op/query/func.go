@@ -0,0 +1,72 @@
+package query
+
+import (
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-bvh/internal/cache"
+	"github.com/downflux/go-bvh/internal/cache/node"
+	"github.com/downflux/go-geometry/nd/hyperrectangle"
+	"github.com/downflux/go-geometry/nd/ray"
+
+	cid "github.com/downflux/go-bvh/internal/cache/id"
+)
+
+// BroadPhaseFunc calls f on each object which touches the query AABB. The
+// traversal stops early if f returns false.
+//
+// The input open buffer is used as the traversal stack, and the (possibly
+// grown) buffer is returned to the caller. Reusing this buffer across calls
+// allows steady-state queries to run without any heap allocations.
+func BroadPhaseFunc(c *cache.C, root cid.ID, data map[id.ID]hyperrectangle.R, open []node.N, q hyperrectangle.R, f func(x id.ID) bool) []node.N {
+	return QueryFunc(c, root, data, open, func(r hyperrectangle.R) bool {
+		return !hyperrectangle.Disjoint(q, r)
+	}, f)
+}
+
+// RaycastFunc calls f on each object which intersects the input ray. The
+// traversal stops early if f returns false. Objects are visited in no
+// particular order.
+//
+// See BroadPhaseFunc for the semantics of the open buffer.
+func RaycastFunc(c *cache.C, root cid.ID, data map[id.ID]hyperrectangle.R, open []node.N, q ray.R, f func(x id.ID) bool) []node.N {
+	return QueryFunc(c, root, data, open, func(r hyperrectangle.R) bool {
+		return Intersect(q, r)
+	}, f)
+}
+
+// QueryFunc calls f on each object which passes the input filter. As with
+// Query, the filter is recursively applied to the internal BVH nodes. The
+// traversal stops early if f returns false.
+//
+// See BroadPhaseFunc for the semantics of the open buffer.
+func QueryFunc(c *cache.C, root cid.ID, data map[id.ID]hyperrectangle.R, open []node.N, q func(r hyperrectangle.R) bool, f func(x id.ID) bool) []node.N {
+	open = open[:0]
+
+	n, ok := c.Get(root)
+	if !ok {
+		return open
+	}
+
+	open = append(open, n)
+
+	var m node.N
+	for len(open) > 0 {
+		m, open = open[len(open)-1], open[:len(open)-1]
+		if m.IsLeaf() {
+			for x := range m.Leaves() {
+				if q(data[x]) && !f(x) {
+					return open[:0]
+				}
+			}
+		} else {
+			l, r := m.Left(), m.Right()
+			if q(l.AABB().R()) {
+				open = append(open, l)
+			}
+			if q(r.AABB().R()) {
+				open = append(open, r)
+			}
+		}
+	}
+
+	return open
+}
@@ -0,0 +1,107 @@
+package query
+
+import (
+	"sort"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-bvh/internal/cache"
+	"github.com/downflux/go-bvh/internal/cache/node"
+	"github.com/downflux/go-geometry/nd/hyperrectangle"
+	"github.com/downflux/go-geometry/nd/vector"
+	"github.com/downflux/go-pq/pq"
+
+	cid "github.com/downflux/go-bvh/internal/cache/id"
+)
+
+// candidate is a priority queue element for the KNN search. Each element
+// tracks either an internal BVH node or a single object, but not both.
+type candidate struct {
+	n node.N
+	x id.ID
+
+	// d is the distance of the object to the query point, and is set when
+	// the object is popped from the queue.
+	d float64
+}
+
+// KNN returns the k objects closest to the input point p, ordered by
+// increasing distance. The distance between a point and an object is defined
+// as the Euclidean distance between the point and the closest point on the
+// object AABB; points within the AABB have a distance of zero.
+//
+// This function implements a best-first traversal (Hjaltason and Samet 1999)
+// -- nodes and objects share a single priority queue, keyed by their distance
+// to p. Because the (buffered) AABB of a node always contains its children, the
+// distance of a node is a lower bound for the distance of any object in its
+// subtree, and thus the n-th object popped from the queue is the n-th closest
+// object to p. Ties between equidistant objects are broken by object ID.
+func KNN(c *cache.C, root cid.ID, data map[id.ID]hyperrectangle.R, p vector.V, k int) []id.ID {
+	n, ok := c.Get(root)
+	if !ok || k <= 0 {
+		return []id.ID{}
+	}
+
+	q := pq.New[candidate](0, pq.PMin)
+	q.Push(candidate{n: n}, D(p, n.AABB().R()))
+
+	// objects tracks all objects popped from the queue.
+	objects := make([]candidate, 0, k)
+
+	for q.Len() > 0 {
+		// Continue to pop objects which are tied with the k-th closest
+		// object, so that ties may be broken deterministically below.
+		if len(objects) >= k && q.Priority() > objects[k-1].d {
+			break
+		}
+
+		m, d := q.Pop()
+		if m.n == nil {
+			m.d = d
+			objects = append(objects, m)
+			continue
+		}
+
+		if m.n.IsLeaf() {
+			for x := range m.n.Leaves() {
+				q.Push(candidate{x: x}, D(p, data[x]))
+			}
+		} else {
+			l, r := m.n.Left(), m.n.Right()
+			q.Push(candidate{n: l}, D(p, l.AABB().R()))
+			q.Push(candidate{n: r}, D(p, r.AABB().R()))
+		}
+	}
+
+	// Ties between equidistant objects are broken by the object ID.
+	sort.SliceStable(objects, func(i, j int) bool {
+		a, b := objects[i], objects[j]
+		return a.d < b.d || (a.d == b.d && a.x < b.x)
+	})
+	if len(objects) > k {
+		objects = objects[:k]
+	}
+
+	ids := make([]id.ID, 0, len(objects))
+	for _, m := range objects {
+		ids = append(ids, m.x)
+	}
+	return ids
+}
+
+// D returns the squared Euclidean distance between the point p and the closest
+// point in the AABB r.
+func D(p vector.V, r hyperrectangle.R) float64 {
+	rmin, rmax := r.Min(), r.Max()
+
+	var d float64
+	for i := vector.D(0); i < p.Dimension(); i++ {
+		var e float64
+		if x := p[i]; x < rmin[i] {
+			e = rmin[i] - x
+		} else if x > rmax[i] {
+			e = x - rmax[i]
+		}
+		d += e * e
+	}
+	return d
+}
@@ -0,0 +1,131 @@
+package query
+
+import (
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-bvh/internal/cache"
+	"github.com/downflux/go-bvh/internal/cache/node"
+	"github.com/downflux/go-geometry/nd/hyperrectangle"
+
+	cid "github.com/downflux/go-bvh/internal/cache/id"
+)
+
+// P is a pair of objects whose AABBs overlap.
+type P struct {
+	A id.ID
+	B id.ID
+}
+
+// Pairs returns all unordered pairs of objects in the tree whose AABBs
+// overlap. Each pair is reported exactly once, with P.A < P.B.
+//
+// This function performs a simultaneous descent of the tree against itself --
+// the pairs within a subtree rooted at n are the pairs within each of its
+// children, plus the pairs spanning the two children. Spanning pairs are only
+// searched if the AABBs of the two (sub)trees overlap.
+func Pairs(c *cache.C, root cid.ID, data map[id.ID]hyperrectangle.R) []P {
+	n, ok := c.Get(root)
+	if !ok {
+		return []P{}
+	}
+
+	pairs := make([]P, 0, 128)
+	f := func(x, y id.ID) {
+		if y < x {
+			x, y = y, x
+		}
+		pairs = append(pairs, P{A: x, B: y})
+	}
+
+	open := make([]node.N, 0, 128)
+	open = append(open, n)
+
+	cross := make([][2]node.N, 0, 128)
+
+	var m node.N
+	for len(open) > 0 {
+		m, open = open[len(open)-1], open[:len(open)-1]
+		if m.IsLeaf() {
+			xs := m.Leaves()
+			for x := range xs {
+				for y := range xs {
+					if x < y && !hyperrectangle.Disjoint(data[x], data[y]) {
+						f(x, y)
+					}
+				}
+			}
+		} else {
+			open = append(open, m.Left(), m.Right())
+			cross = overlap(data, data, m.Left(), m.Right(), cross, f)
+		}
+	}
+
+	return pairs
+}
+
+// CrossPairs returns all pairs of objects (x, y) where x is an object in the
+// first tree, y is an object in the second tree, and the AABBs of x and y
+// overlap. Here, P.A is the object in the first tree, and P.B the object in the
+// second.
+//
+// The two trees are traversed simultaneously, and only subtrees with
+// overlapping AABBs are searched.
+func CrossPairs(
+	cs *cache.C, rs cid.ID, ds map[id.ID]hyperrectangle.R,
+	ct *cache.C, rt cid.ID, dt map[id.ID]hyperrectangle.R,
+) []P {
+	s, ok := cs.Get(rs)
+	if !ok {
+		return []P{}
+	}
+	t, ok := ct.Get(rt)
+	if !ok {
+		return []P{}
+	}
+
+	pairs := make([]P, 0, 128)
+	overlap(ds, dt, s, t, make([][2]node.N, 0, 128), func(x, y id.ID) {
+		pairs = append(pairs, P{A: x, B: y})
+	})
+
+	return pairs
+}
+
+// overlap calls f on each pair of objects (x, y) where x is in the subtree
+// rooted at a, y is in the subtree rooted at b, and the AABBs of x and y
+// overlap. The object AABBs of the subtrees are given by da and db
+// respectively, which allows a and b to belong to different trees.
+//
+// The input open buffer is used as the traversal stack, and is returned to the
+// caller for reuse.
+func overlap(da map[id.ID]hyperrectangle.R, db map[id.ID]hyperrectangle.R, a node.N, b node.N, open [][2]node.N, f func(x, y id.ID)) [][2]node.N {
+	open = append(open[:0], [2]node.N{a, b})
+
+	var m [2]node.N
+	for len(open) > 0 {
+		m, open = open[len(open)-1], open[:len(open)-1]
+		s, t := m[0], m[1]
+
+		if hyperrectangle.Disjoint(s.AABB().R(), t.AABB().R()) {
+			continue
+		}
+
+		switch {
+		case s.IsLeaf() && t.IsLeaf():
+			for x := range s.Leaves() {
+				for y := range t.Leaves() {
+					if !hyperrectangle.Disjoint(da[x], db[y]) {
+						f(x, y)
+					}
+				}
+			}
+		// Descend into the larger internal node first in order to
+		// shrink the search volume as quickly as possible.
+		case t.IsLeaf() || !s.IsLeaf() && s.Heuristic() >= t.Heuristic():
+			open = append(open, [2]node.N{s.Left(), t}, [2]node.N{s.Right(), t})
+		default:
+			open = append(open, [2]node.N{s, t.Left()}, [2]node.N{s, t.Right()})
+		}
+	}
+
+	return open
+}
@@ -30,10 +30,10 @@ func Raycast(c *cache.C, root cid.ID, data map[id.ID]hyperrectangle.R, q ray.R)
 			}
 		} else {
 			l, r := m.Left(), m.Right()
-			if ray.IntersectHyperrectangle(q, l.AABB().R()) {
+			if Intersect(q, l.AABB().R()) {
 				open = append(open, l)
 			}
-			if ray.IntersectHyperrectangle(q, r.AABB().R()) {
+			if Intersect(q, r.AABB().R()) {
 				open = append(open, r)
 			}
 		}
@@ -41,7 +41,7 @@ func Raycast(c *cache.C, root cid.ID, data map[id.ID]hyperrectangle.R, q ray.R)
 
 	ids := make([]id.ID, 0, len(candidates))
 	for _, x := range candidates {
-		if ray.IntersectHyperrectangle(q, data[x]) {
+		if Intersect(q, data[x]) {
 			ids = append(ids, x)
 		}
 	}
@@ -0,0 +1,160 @@
+package query
+
+import (
+	"math"
+	"sort"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-bvh/internal/cache"
+	"github.com/downflux/go-bvh/internal/cache/node"
+	"github.com/downflux/go-geometry/nd/hyperrectangle"
+	"github.com/downflux/go-geometry/nd/ray"
+	"github.com/downflux/go-geometry/nd/vector"
+	"github.com/downflux/go-pq/pq"
+
+	cid "github.com/downflux/go-bvh/internal/cache/id"
+)
+
+// Hit is a ray intersection with an object. T is the ray parameter at which
+// the ray enters the object AABB; as ray directions are normalized, this is
+// also the distance from the ray origin to the entry point. If the ray origin
+// lies within the object AABB, T is zero.
+type Hit struct {
+	ID id.ID
+	T  float64
+}
+
+// RaycastFirst returns the object closest to the ray origin which intersects
+// the ray within the parametric distance max. The input max may be set to
+// math.Inf(1) to treat the ray as unbounded, or to some finite value to treat
+// the ray as a segment.
+//
+// Nodes are traversed front-to-back, and any node whose entry distance is
+// greater than the closest hit found so far is pruned.
+func RaycastFirst(c *cache.C, root cid.ID, data map[id.ID]hyperrectangle.R, q ray.R, max float64) (Hit, bool) {
+	n, ok := c.Get(root)
+	if !ok {
+		return Hit{}, false
+	}
+
+	t, ok := IntersectT(q, n.AABB().R())
+	if !ok || t > max {
+		return Hit{}, false
+	}
+
+	open := pq.New[node.N](0, pq.PMin)
+	open.Push(n, t)
+
+	var h Hit
+	found := false
+	// best tracks the entry distance of the closest hit found so far.
+	best := max
+
+	for open.Len() > 0 {
+		m, t := open.Pop()
+		// All remaining nodes in the queue are at least as far away as
+		// m, and thus cannot contain a closer hit.
+		if t > best {
+			break
+		}
+
+		if m.IsLeaf() {
+			for x := range m.Leaves() {
+				if t, ok := IntersectT(q, data[x]); ok && t <= best && (!found || t < h.T || (t == h.T && x < h.ID)) {
+					h = Hit{ID: x, T: t}
+					best = t
+					found = true
+				}
+			}
+		} else {
+			for _, o := range []node.N{m.Left(), m.Right()} {
+				if t, ok := IntersectT(q, o.AABB().R()); ok && t <= best {
+					open.Push(o, t)
+				}
+			}
+		}
+	}
+
+	return h, found
+}
+
+// RaycastSorted returns all objects which intersect the ray within the
+// parametric distance max, sorted by increasing entry distance (and then by
+// object ID). As with RaycastFirst, the input max may be set to math.Inf(1) for
+// an unbounded ray.
+func RaycastSorted(c *cache.C, root cid.ID, data map[id.ID]hyperrectangle.R, q ray.R, max float64) []Hit {
+	n, ok := c.Get(root)
+	if !ok {
+		return []Hit{}
+	}
+
+	open := make([]node.N, 0, 128)
+	open = append(open, n)
+
+	hits := make([]Hit, 0, 128)
+
+	var m node.N
+	for len(open) > 0 {
+		m, open = open[len(open)-1], open[:len(open)-1]
+		if t, ok := IntersectT(q, m.AABB().R()); !ok || t > max {
+			continue
+		}
+		if m.IsLeaf() {
+			for x := range m.Leaves() {
+				if t, ok := IntersectT(q, data[x]); ok && t <= max {
+					hits = append(hits, Hit{ID: x, T: t})
+				}
+			}
+		} else {
+			open = append(open, m.Left(), m.Right())
+		}
+	}
+
+	// Ties between hits are broken by the object ID.
+	sort.Slice(hits, func(i, j int) bool {
+		return hits[i].T < hits[j].T || (hits[i].T == hits[j].T && hits[i].ID < hits[j].ID)
+	})
+	return hits
+}
+
+// Intersect checks if the input ray intersects the AABB s. This is the
+// intersection test used by all raycast queries.
+func Intersect(r ray.R, s hyperrectangle.R) bool {
+	_, ok := IntersectT(r, s)
+	return ok
+}
+
+// IntersectT checks if the input ray intersects the AABB s, and if so, returns
+// the ray parameter at which the ray enters s. If the ray origin lies within s,
+// the returned parameter is zero.
+//
+// This is the slab test as described in Kay and Kajiya 1986.
+func IntersectT(r ray.R, s hyperrectangle.R) (float64, bool) {
+	p, d := r.P(), r.D()
+	smin, smax := s.Min(), s.Max()
+
+	tmin, tmax := 0.0, math.Inf(1)
+	for i := vector.D(0); i < p.Dimension(); i++ {
+		if d[i] == 0 {
+			// The ray is parallel to the slab along this axis, and
+			// can only intersect if the origin lies within the slab.
+			if p[i] < smin[i] || p[i] > smax[i] {
+				return 0, false
+			}
+			continue
+		}
+
+		u := 1 / d[i]
+		tl, tr := (smin[i]-p[i])*u, (smax[i]-p[i])*u
+		if tl > tr {
+			tl, tr = tr, tl
+		}
+
+		tmin = math.Max(tmin, tl)
+		tmax = math.Min(tmax, tr)
+		if tmin > tmax {
+			return 0, false
+		}
+	}
+	return tmin, true
+}
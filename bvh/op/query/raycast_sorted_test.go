@@ -0,0 +1,68 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/downflux/go-geometry/epsilon"
+	"github.com/downflux/go-geometry/nd/hyperrectangle"
+	"github.com/downflux/go-geometry/nd/ray"
+	"github.com/downflux/go-geometry/nd/vector"
+)
+
+func TestIntersectT(t *testing.T) {
+	type config struct {
+		name string
+		r    ray.R
+		s    hyperrectangle.R
+		ok   bool
+		t    float64
+	}
+
+	configs := []config{
+		{
+			name: "Miss",
+			r:    *ray.New(vector.V{0, 0}, vector.V{1, 0}),
+			s:    *hyperrectangle.New(vector.V{1, 1}, vector.V{2, 2}),
+			ok:   false,
+		},
+		{
+			name: "Behind",
+			r:    *ray.New(vector.V{3, 1.5}, vector.V{1, 0}),
+			s:    *hyperrectangle.New(vector.V{1, 1}, vector.V{2, 2}),
+			ok:   false,
+		},
+		{
+			name: "Axis",
+			r:    *ray.New(vector.V{0, 1.5}, vector.V{1, 0}),
+			s:    *hyperrectangle.New(vector.V{1, 1}, vector.V{2, 2}),
+			ok:   true,
+			t:    1,
+		},
+		{
+			name: "Diagonal",
+			r:    *ray.New(vector.V{0, 0}, vector.V{1, 1}),
+			s:    *hyperrectangle.New(vector.V{1, 1}, vector.V{2, 2}),
+			ok:   true,
+			t:    vector.Magnitude(vector.V{1, 1}),
+		},
+		{
+			name: "Inside",
+			r:    *ray.New(vector.V{1.5, 1.5}, vector.V{-1, 0}),
+			s:    *hyperrectangle.New(vector.V{1, 1}, vector.V{2, 2}),
+			ok:   true,
+			t:    0,
+		},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			got, ok := IntersectT(c.r, c.s)
+			if ok != c.ok {
+				t.Fatalf("IntersectT() = _, %v, want = _, %v", ok, c.ok)
+			}
+			if ok && !epsilon.Within(got, c.t) {
+				t.Errorf("IntersectT() = %v, _, want = %v, _", got, c.t)
+			}
+		})
+	}
+}
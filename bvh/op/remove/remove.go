@@ -21,28 +21,20 @@ type O struct {
 	Balance balance.B
 }
 
-func Remove(c *cache.C, data map[id.ID]hyperrectangle.R, x cid.ID, y id.ID, tolerance float64) node.N {
-	return Default.Remove(c, data, x, y, tolerance)
+func Remove(c *cache.C, data map[id.ID]hyperrectangle.R, static map[id.ID]struct{}, x cid.ID, y id.ID, tolerance float64) node.N {
+	return Default.Remove(c, data, static, x, y, tolerance)
 }
 
 // Remove deletes a leaf from a node. If necessary, this function will merge
 // leaf nodes.
 //
 // This function returns the new root node.
-func (o O) Remove(c *cache.C, data map[id.ID]hyperrectangle.R, x cid.ID, y id.ID, tolerance float64) node.N {
-	n, ok := c.Get(x)
-	if !ok {
-		return nil
-	}
-
-	delete(n.Leaves(), y)
-	if len(n.Leaves()) == 0 {
-		n = unsafe.Remove(c, n)
-	}
+func (o O) Remove(c *cache.C, data map[id.ID]hyperrectangle.R, static map[id.ID]struct{}, x cid.ID, y id.ID, tolerance float64) node.N {
+	n := Detach(c, x, y)
 
 	var root node.N
 	for m := n; m != nil; m = m.Parent() {
-		node.SetAABB(m, data, tolerance)
+		node.SetAABB(m, data, static, tolerance)
 		node.SetHeight(m)
 
 		if !m.IsLeaf() {
@@ -56,3 +48,22 @@ func (o O) Remove(c *cache.C, data map[id.ID]hyperrectangle.R, x cid.ID, y id.ID
 
 	return root
 }
+
+// Detach deletes a leaf from a node as per Remove, but does not update the
+// node caches or rebalance the tree.
+//
+// This function returns the node from which the caller must update the tree,
+// i.e. the input node if it still contains other objects, or the sibling of the
+// input node otherwise. If the tree is now empty, this function returns nil.
+func Detach(c *cache.C, x cid.ID, y id.ID) node.N {
+	n, ok := c.Get(x)
+	if !ok {
+		return nil
+	}
+
+	delete(n.Leaves(), y)
+	if len(n.Leaves()) == 0 {
+		n = unsafe.Remove(c, n)
+	}
+	return n
+}
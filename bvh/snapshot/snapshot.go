@@ -0,0 +1,240 @@
+// Package snapshot implements an immutable, query-only view of a BVH tree.
+//
+// A snapshot flattens the tree nodes and object AABBs into a small number of
+// contiguous arrays. Because the snapshot does not share any memory with the
+// source tree, it may be safely queried from multiple goroutines while the
+// source tree is being mutated.
+package snapshot
+
+import (
+	"sort"
+
+	"github.com/downflux/go-bvh/bvh/op/query"
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-bvh/internal/cache"
+	"github.com/downflux/go-bvh/internal/cache/node"
+	"github.com/downflux/go-bvh/internal/cache/node/util"
+	"github.com/downflux/go-geometry/nd/hyperrectangle"
+	"github.com/downflux/go-geometry/nd/ray"
+	"github.com/downflux/go-geometry/nd/vector"
+	"github.com/downflux/go-pq/pq"
+
+	cid "github.com/downflux/go-bvh/internal/cache/id"
+)
+
+// n is a flattened tree node. Nodes are stored in pre-order, so the left child
+// of an internal node is always stored directly after the node itself.
+type n struct {
+	aabb hyperrectangle.R
+
+	// right is the index of the right child of an internal node, and is
+	// set to -1 for leaf nodes.
+	right int
+
+	// offset and size track the range of objects in the object arrays
+	// which belong to a leaf node.
+	offset int
+	size   int
+}
+
+func (n n) isLeaf() bool { return n.right < 0 }
+
+type S struct {
+	// deterministic indicates the query results should be returned in
+	// increasing ID order, as per the source tree.
+	deterministic bool
+
+	nodes []n
+
+	ids  []id.ID
+	data []hyperrectangle.R
+}
+
+// New flattens the subtree rooted at the input node into a snapshot. If
+// deterministic is set, the snapshot query results are sorted by object ID.
+func New(c *cache.C, root cid.ID, data map[id.ID]hyperrectangle.R, deterministic bool) *S {
+	r, ok := c.Get(root)
+	if !ok {
+		return &S{deterministic: deterministic}
+	}
+
+	var nnodes int
+	util.PreOrder(r, func(node.N) { nnodes++ })
+
+	k := int(c.K())
+
+	// All AABB coordinates are backed by a single buffer.
+	buf := make([]float64, 0, 2*k*(nnodes+len(data)))
+	alloc := func(r hyperrectangle.R) hyperrectangle.R {
+		buf = append(buf, r.Min()...)
+		buf = append(buf, r.Max()...)
+		b := buf[len(buf)-2*k:]
+		return *hyperrectangle.New(vector.V(b[:k:k]), vector.V(b[k:]))
+	}
+
+	s := &S{
+		deterministic: deterministic,
+
+		nodes: make([]n, 0, nnodes),
+		ids:   make([]id.ID, 0, len(data)),
+		data:  make([]hyperrectangle.R, 0, len(data)),
+	}
+
+	var flatten func(m node.N) int
+	flatten = func(m node.N) int {
+		i := len(s.nodes)
+		s.nodes = append(s.nodes, n{
+			aabb:  alloc(m.AABB().R()),
+			right: -1,
+		})
+
+		if m.IsLeaf() {
+			offset := len(s.ids)
+			for x := range m.Leaves() {
+				s.ids = append(s.ids, x)
+			}
+			xs := s.ids[offset:]
+			sort.Slice(xs, func(i, j int) bool { return xs[i] < xs[j] })
+			for _, x := range xs {
+				s.data = append(s.data, alloc(data[x]))
+			}
+
+			s.nodes[i].offset = offset
+			s.nodes[i].size = len(xs)
+			return i
+		}
+
+		flatten(m.Left())
+		s.nodes[i].right = flatten(m.Right())
+		return i
+	}
+	flatten(r)
+
+	return s
+}
+
+// Len returns the number of objects in the snapshot.
+func (s *S) Len() int { return len(s.ids) }
+
+// IDs returns all objects in the snapshot.
+func (s *S) IDs() []id.ID {
+	ids := make([]id.ID, len(s.ids))
+	copy(ids, s.ids)
+	return ids
+}
+
+// BroadPhase finds all objects which intersect with the given input AABB.
+func (s *S) BroadPhase(q hyperrectangle.R) []id.ID {
+	return s.Query(func(r hyperrectangle.R) bool { return !hyperrectangle.Disjoint(q, r) })
+}
+
+// Raycast finds all objects which intersect the given ray.
+func (s *S) Raycast(q ray.R) []id.ID {
+	return s.Query(func(r hyperrectangle.R) bool { return query.Intersect(q, r) })
+}
+
+// Query finds all objects which pass the input filtering function. As with
+// bvh.T.Query, the children of an internal node are only searched if the
+// parent AABB also passes the filter, and the results are sorted by object ID
+// if the source tree is in deterministic mode.
+func (s *S) Query(f func(r hyperrectangle.R) bool) []id.ID {
+	if len(s.nodes) == 0 {
+		return []id.ID{}
+	}
+
+	open := make([]int, 0, 128)
+	open = append(open, 0)
+
+	ids := make([]id.ID, 0, 128)
+
+	var i int
+	for len(open) > 0 {
+		i, open = open[len(open)-1], open[:len(open)-1]
+		m := s.nodes[i]
+		if m.isLeaf() {
+			for j := m.offset; j < m.offset+m.size; j++ {
+				if f(s.data[j]) {
+					ids = append(ids, s.ids[j])
+				}
+			}
+			continue
+		}
+
+		if f(s.nodes[i+1].aabb) {
+			open = append(open, i+1)
+		}
+		if f(s.nodes[m.right].aabb) {
+			open = append(open, m.right)
+		}
+	}
+
+	if s.deterministic {
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	}
+	return ids
+}
+
+// candidate is a priority queue element for the KNN search, which tracks
+// either a node or a single object.
+type candidate struct {
+	node   int
+	object int
+
+	// d is the distance of the object to the query point, and is set when
+	// the object is popped from the queue.
+	d float64
+}
+
+// KNN returns the k objects closest to the input point p, ordered by
+// increasing distance, where ties between equidistant objects are broken by
+// object ID. See bvh.T.KNN for more details.
+func (s *S) KNN(p vector.V, k int) []id.ID {
+	if len(s.nodes) == 0 || k <= 0 {
+		return []id.ID{}
+	}
+
+	q := pq.New[candidate](0, pq.PMin)
+	q.Push(candidate{node: 0, object: -1}, query.D(p, s.nodes[0].aabb))
+
+	// objects tracks all objects popped from the queue.
+	objects := make([]candidate, 0, k)
+
+	for q.Len() > 0 {
+		// Continue to pop objects which are tied with the k-th closest
+		// object, so that ties may be broken deterministically below.
+		if len(objects) >= k && q.Priority() > objects[k-1].d {
+			break
+		}
+
+		c, d := q.Pop()
+		if c.object >= 0 {
+			c.d = d
+			objects = append(objects, c)
+			continue
+		}
+
+		m := s.nodes[c.node]
+		if m.isLeaf() {
+			for j := m.offset; j < m.offset+m.size; j++ {
+				q.Push(candidate{node: -1, object: j}, query.D(p, s.data[j]))
+			}
+		} else {
+			q.Push(candidate{node: c.node + 1, object: -1}, query.D(p, s.nodes[c.node+1].aabb))
+			q.Push(candidate{node: m.right, object: -1}, query.D(p, s.nodes[m.right].aabb))
+		}
+	}
+
+	sort.SliceStable(objects, func(i, j int) bool {
+		a, b := objects[i], objects[j]
+		return a.d < b.d || (a.d == b.d && s.ids[a.object] < s.ids[b.object])
+	})
+	if len(objects) > k {
+		objects = objects[:k]
+	}
+
+	ids := make([]id.ID, 0, len(objects))
+	for _, c := range objects {
+		ids = append(ids, s.ids[c.object])
+	}
+	return ids
+}
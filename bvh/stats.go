@@ -0,0 +1,145 @@
+package bvh
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/downflux/go-bvh/bvh/op/query"
+	"github.com/downflux/go-bvh/internal/cache/node"
+	"github.com/downflux/go-bvh/internal/cache/node/util"
+	"github.com/downflux/go-bvh/internal/cache/node/util/metrics"
+	"github.com/downflux/go-geometry/nd/hyperrectangle"
+	"github.com/downflux/go-geometry/nd/ray"
+	"github.com/downflux/go-geometry/nd/vector"
+)
+
+const (
+	// nLCVRays is the number of sample rays used to estimate the leaf
+	// count variability of the tree.
+	nLCVRays = 256
+)
+
+var (
+	// CostAila specifies the SAH cost constants used in Aila et al. 2013.
+	CostAila = Cost(metrics.AilaC)
+)
+
+// Cost specifies the relative cost of traversing an internal node, a leaf node,
+// and testing an individual object for intersection, as used in the SAH and EPO
+// calculations.
+type Cost struct {
+	Internal float64
+	Leaf     float64
+	Object   float64
+}
+
+// Stats tracks a snapshot of various tree quality metrics.
+type Stats struct {
+	// Nodes is the total number of nodes in the tree.
+	Nodes int
+
+	// Leaves is the number of leaf nodes in the tree.
+	Leaves int
+
+	// Objects is the number of objects in the tree.
+	Objects int
+
+	// Height is the height of the root node. A tree with a single leaf has
+	// height 0.
+	Height int
+
+	// LeafSizeMin, LeafSizeMax, and LeafSizeAvg track the number of objects
+	// stored in each leaf node.
+	LeafSizeMin int
+	LeafSizeMax int
+	LeafSizeAvg float64
+
+	// SAH is the surface area heuristic as defined by MacDonald and Booth
+	// 1990.
+	SAH float64
+
+	// Margin is the total volume of the fat buffers added around the leaf
+	// nodes, i.e. the sum of the differences between each leaf AABB and the
+	// tight bounding box of its objects.
+	Margin float64
+
+	// Freed is the number of nodes in the internal free pool which are
+	// available for reuse.
+	Freed int
+
+	// EPO is the effective primitive overlap as defined in Aila et al.
+	// 2013, which measures how much of a leaf query will traverse nodes
+	// which do not contain the query result.
+	EPO float64
+
+	// LCV is the leaf count variability as defined in Aila et al. 2013,
+	// i.e. the standard deviation of the number of leaves visited by a
+	// query. Here, this is estimated with a fixed set of pseudo-random
+	// sample rays originating in the tree AABB.
+	LCV float64
+}
+
+// Stats returns the current tree quality metrics. The input cost constants are
+// used for the SAH and EPO calculations; most users should use CostAila here.
+//
+// N.B.: The EPO calculation is quadratic in the worst case, and is therefore
+// meant for offline analysis rather than per-frame reporting.
+func (t *T) Stats(c Cost) Stats {
+	s := Stats{
+		Objects: len(t.data),
+		Freed:   t.c.Freed(),
+	}
+
+	root, ok := t.c.Get(t.root)
+	if !ok {
+		return s
+	}
+
+	s.Height = root.Height()
+	s.LeafSizeMin = math.MaxInt
+	util.PreOrder(root, func(n node.N) {
+		s.Nodes++
+		if !n.IsLeaf() {
+			return
+		}
+		s.Leaves++
+		if m := len(n.Leaves()); m < s.LeafSizeMin {
+			s.LeafSizeMin = m
+		}
+		if m := len(n.Leaves()); m > s.LeafSizeMax {
+			s.LeafSizeMax = m
+		}
+	})
+	s.LeafSizeAvg = float64(s.Objects) / float64(s.Leaves)
+
+	s.SAH = metrics.C(c).SAH(root)
+	s.EPO = metrics.C(c).EPO(root, t.data)
+	s.Margin = metrics.Margin(root, t.data)
+	s.LCV = metrics.LCV(root, rays(root.AABB().R()))
+
+	return s
+}
+
+// rays generates a deterministic set of ray intersection filters, whose rays
+// originate from within the input AABB.
+func rays(aabb hyperrectangle.R) []func(r hyperrectangle.R) bool {
+	rng := rand.New(rand.NewSource(0))
+
+	k := aabb.Min().Dimension()
+	qs := make([]func(r hyperrectangle.R) bool, 0, nLCVRays)
+	for i := 0; i < nLCVRays; i++ {
+		p := make([]float64, k)
+		d := make([]float64, k)
+		for j := vector.D(0); j < k; j++ {
+			p[j] = aabb.Min()[j] + rng.Float64()*(aabb.Max()[j]-aabb.Min()[j])
+			d[j] = rng.NormFloat64()
+		}
+
+		q := *ray.New(vector.V(p), vector.V(d))
+		qs = append(qs, func(r hyperrectangle.R) bool {
+			_, ok := query.IntersectT(q, r)
+			return ok
+		})
+	}
+	return qs
+}
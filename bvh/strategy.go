@@ -0,0 +1,195 @@
+package bvh
+
+import (
+	"fmt"
+
+	"github.com/downflux/go-bvh/bvh/op/build"
+	"github.com/downflux/go-bvh/internal/cache/op/balance"
+	"github.com/downflux/go-bvh/internal/cache/op/candidate"
+	"github.com/downflux/go-bvh/internal/cache/op/split"
+	"github.com/downflux/go-bvh/internal/heuristic"
+)
+
+// Build is a bulk-load strategy for NewFromData.
+type Build int
+
+const (
+	// BuildSAH constructs the tree top-down with binned SAH splits. This
+	// is the default strategy.
+	BuildSAH Build = iota
+
+	// BuildMorton constructs a linear BVH by sorting objects along a
+	// Z-order curve. This is much faster than BuildSAH for very large data
+	// sets, but generates lower-quality trees.
+	BuildMorton
+
+	// BuildMortonOptimized constructs a linear BVH as per BuildMorton, and
+	// then restructures every treelet of (up to) four leaves, which is a
+	// faster but less thorough pass than BuildMortonRestructured.
+	BuildMortonOptimized
+
+	// BuildSAHRestructured and BuildMortonRestructured construct the tree
+	// as per BuildSAH and BuildMorton respectively, and then restructure
+	// every treelet of (up to) seven leaves into its optimal topology, as
+	// per Karras and Aila 2013. This generates the highest quality trees,
+	// at the cost of a slower build.
+	BuildSAHRestructured
+	BuildMortonRestructured
+)
+
+func (b Build) builder() build.B {
+	switch b {
+	case BuildSAH:
+		return build.SAH
+	case BuildMorton:
+		return build.Morton
+	case BuildMortonOptimized:
+		return build.Optimized(build.Morton)
+	case BuildSAHRestructured:
+		return build.Restructured(build.SAH)
+	case BuildMortonRestructured:
+		return build.Restructured(build.Morton)
+	default:
+		panic(fmt.Sprintf("invalid build strategy %v", b))
+	}
+}
+
+// Candidate is the strategy used to find the leaf node into which a new
+// object is inserted.
+type Candidate int
+
+const (
+	// CandidateGuttman greedily descends into the child with the smallest
+	// increase in cost, as per Guttman 1984. This is the default strategy,
+	// and is the cheapest option, which makes it suitable for trees with
+	// frequent updates.
+	CandidateGuttman Candidate = iota
+
+	// CandidateBittner uses the branch and bound search in Bittner et al.
+	// 2013 to find the sibling which minimizes the total tree cost. This
+	// is slower than CandidateGuttman, but will generate higher quality
+	// trees, and is suitable for mostly static scenes.
+	CandidateBittner
+
+	// CandidateCatto uses the greedy SAH descent in the Box2D
+	// implementation, as described in Catto 2019.
+	CandidateCatto
+
+	// CandidateDHConnelly uses the node selection in the rtreego
+	// implementation.
+	CandidateDHConnelly
+
+	// CandidateRStar uses the ChooseSubtree algorithm in Beckmann et al.
+	// 1990, which minimizes the overlap between sibling leaf nodes. This
+	// may reduce the number of leaf nodes visited by a query.
+	CandidateRStar
+)
+
+func (c Candidate) candidate() candidate.C {
+	switch c {
+	case CandidateGuttman:
+		return candidate.Guttman
+	case CandidateBittner:
+		return candidate.Bittner
+	case CandidateCatto:
+		return candidate.Catto
+	case CandidateDHConnelly:
+		return candidate.DHConnelly
+	case CandidateRStar:
+		return candidate.RStar
+	default:
+		panic(fmt.Sprintf("invalid candidate strategy %v", c))
+	}
+}
+
+// Split is the strategy used to partition the objects of a full leaf node.
+// This option has no effect for trees with a leaf size of one.
+type Split int
+
+const (
+	// SplitGuttmanLinear uses the linear split in Guttman 1984. This is
+	// the default strategy.
+	SplitGuttmanLinear Split = iota
+
+	// SplitDHConnelly uses the quadratic split in the rtreego
+	// implementation.
+	SplitDHConnelly
+)
+
+func (s Split) split() split.S {
+	switch s {
+	case SplitGuttmanLinear:
+		return split.GuttmanLinear
+	case SplitDHConnelly:
+		return split.DHConnelly
+	default:
+		panic(fmt.Sprintf("invalid split strategy %v", s))
+	}
+}
+
+// Balance is the strategy used to rebalance the tree after an insert or
+// remove operation.
+type Balance int
+
+const (
+	// BalanceBrianNoyamaNoDF enforces the AVL height constraint and then
+	// applies the cheaper subset of the tree rotations in Kopta et al. 2012.
+	// This is the default strategy.
+	BalanceBrianNoyamaNoDF Balance = iota
+
+	// BalanceBrianNoyama enforces the AVL height constraint and then applies
+	// all tree rotations in Kopta et al. 2012. This results in slower
+	// mutations.
+	BalanceBrianNoyama
+
+	// BalanceAVL only enforces the AVL height constraint, and does not
+	// attempt to lower the tree cost.
+	BalanceAVL
+
+	// BalanceTreelet enforces the AVL height constraint and then
+	// restructures the local treelet of (up to) five leaves into its
+	// optimal topology, as per Karras and Aila 2013. This finds lower cost
+	// trees than the Kopta et al. rotations, at the cost of slower
+	// mutations and less strictly balanced trees.
+	BalanceTreelet
+)
+
+func (b Balance) balance() balance.B {
+	switch b {
+	case BalanceBrianNoyamaNoDF:
+		return balance.BrianNoyamaNoDF
+	case BalanceBrianNoyama:
+		return balance.BrianNoyama
+	case BalanceAVL:
+		return balance.AVL
+	case BalanceTreelet:
+		return balance.AVLTreelet(5)
+	default:
+		panic(fmt.Sprintf("invalid balance strategy %v", b))
+	}
+}
+
+// Heuristic is the node cost function which the tree strategies attempt to
+// minimize.
+type Heuristic int
+
+const (
+	// HeuristicSA uses the surface area of the node AABB. This is the
+	// default heuristic.
+	HeuristicSA Heuristic = iota
+
+	// HeuristicBrianNoyama uses the total edge length of the node AABB, as
+	// in the briannoyama implementation.
+	HeuristicBrianNoyama
+)
+
+func (h Heuristic) heuristic() heuristic.F {
+	switch h {
+	case HeuristicSA:
+		return heuristic.H
+	case HeuristicBrianNoyama:
+		return heuristic.BrianNoyama
+	default:
+		panic(fmt.Sprintf("invalid heuristic %v", h))
+	}
+}
@@ -0,0 +1,233 @@
+package bvh
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-bvh/internal/cache/node"
+	"github.com/downflux/go-geometry/epsilon"
+	"github.com/downflux/go-geometry/nd/hyperrectangle"
+
+	cid "github.com/downflux/go-bvh/internal/cache/id"
+)
+
+// Check enumerates the tree invariants verified by Validate.
+type Check int
+
+const (
+	// CheckLink indicates a node has an inconsistent parent or child
+	// back-link.
+	CheckLink Check = iota
+
+	// CheckFreed indicates a freed cache node is reachable from the root.
+	CheckFreed
+
+	// CheckLeafSize indicates a leaf node is either empty or holds more
+	// objects than the tree leaf size.
+	CheckLeafSize
+
+	// CheckHeight indicates a node has an incorrect cached height.
+	CheckHeight
+
+	// CheckAABB indicates a node AABB does not contain its children.
+	CheckAABB
+
+	// CheckHeuristic indicates a node has a cached heuristic which does not
+	// match the heuristic of its AABB.
+	CheckHeuristic
+
+	// CheckObject indicates the tree object lookup table is inconsistent
+	// with the objects actually held by the leaf nodes.
+	CheckObject
+)
+
+// Violation describes a single broken tree invariant.
+type Violation struct {
+	Check Check
+
+	// Node is the internal ID of the offending node. This is set to -1 if
+	// the violation is not tied to a specific node.
+	Node int
+
+	Message string
+}
+
+func (v Violation) String() string {
+	if v.Node < 0 {
+		return v.Message
+	}
+	return fmt.Sprintf("node %v: %v", v.Node, v.Message)
+}
+
+// ValidationError lists all violations found by Validate.
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	s := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		s = append(s, v.String())
+	}
+	return fmt.Sprintf("found %v tree violations: %v", len(e.Violations), strings.Join(s, "; "))
+}
+
+// Validate checks the internal consistency of the tree. If any invariant is
+// broken, Validate returns a *ValidationError listing every violation.
+//
+// Validate is meant for debugging, and walks the entire tree.
+func (t *T) Validate() error {
+	var vs []Violation
+	f := func(c Check, x cid.ID, msg string, args ...any) {
+		vs = append(vs, Violation{
+			Check:   c,
+			Node:    int(x),
+			Message: fmt.Sprintf(msg, args...),
+		})
+	}
+
+	// leaves tracks the leaf node which actually holds each object.
+	leaves := make(map[id.ID]cid.ID, len(t.data))
+
+	if t.root.IsValid() {
+		if p, _, _, _ := t.c.Links(t.root); p.IsValid() {
+			f(CheckLink, t.root, "root node has parent %v", p)
+		}
+	}
+
+	type e struct {
+		x cid.ID
+		p cid.ID
+	}
+
+	visited := map[cid.ID]bool{}
+	open := []e{}
+	if t.root.IsValid() {
+		open = append(open, e{x: t.root, p: cid.IDInvalid})
+	}
+
+	var s e
+	for len(open) > 0 {
+		s, open = open[len(open)-1], open[:len(open)-1]
+
+		n, ok := t.c.Get(s.x)
+		if !ok {
+			f(CheckFreed, s.x, "freed node is reachable from parent %v", s.p)
+			continue
+		}
+		if visited[s.x] {
+			f(CheckLink, s.x, "node is reachable via multiple paths")
+			continue
+		}
+		visited[s.x] = true
+
+		p, l, r, _ := t.c.Links(s.x)
+		if p != s.p {
+			f(CheckLink, s.x, "node has parent %v, but is a child of %v", p, s.p)
+		}
+
+		if !epsilon.Within(n.Heuristic(), n.H(n.AABB().R())) {
+			f(CheckHeuristic, s.x, "cached heuristic %v does not match expected value %v", n.Heuristic(), n.H(n.AABB().R()))
+		}
+
+		if l.IsValid() != r.IsValid() {
+			f(CheckLink, s.x, "node has a single child (left = %v, right = %v)", l, r)
+		}
+
+		if !l.IsValid() {
+			t.validateLeaf(n, leaves, f)
+			continue
+		}
+		if !r.IsValid() {
+			continue
+		}
+
+		open = append(open, e{x: r, p: s.x}, e{x: l, p: s.x})
+
+		nl, lok := t.c.Get(l)
+		nr, rok := t.c.Get(r)
+		if !lok || !rok {
+			continue
+		}
+		if h := int(math.Max(float64(nl.Height()), float64(nr.Height()))) + 1; h != n.Height() {
+			f(CheckHeight, s.x, "node has height %v, want = %v", n.Height(), h)
+		}
+		if !hyperrectangle.Contains(n.AABB().R(), nl.AABB().R()) || !hyperrectangle.Contains(n.AABB().R(), nr.AABB().R()) {
+			f(CheckAABB, s.x, "node AABB does not contain its children")
+		}
+	}
+
+	xs := make([]id.ID, 0, len(t.data))
+	for x := range t.data {
+		xs = append(xs, x)
+	}
+	for x := range t.nodes {
+		if _, ok := t.data[x]; !ok {
+			xs = append(xs, x)
+		}
+	}
+	sort.Slice(xs, func(i, j int) bool { return xs[i] < xs[j] })
+
+	for _, x := range xs {
+		if _, ok := t.data[x]; !ok {
+			f(CheckObject, cid.IDInvalid, "object %v has a node mapping but no AABB", x)
+			continue
+		}
+		if _, ok := leaves[x]; !ok {
+			f(CheckObject, cid.IDInvalid, "object %v is not held by any leaf", x)
+		}
+		if _, ok := t.nodes[x]; !ok {
+			f(CheckObject, cid.IDInvalid, "object %v has no node mapping", x)
+		}
+	}
+
+	if len(vs) > 0 {
+		return &ValidationError{Violations: vs}
+	}
+	return nil
+}
+
+// validateLeaf checks the leaf node invariants, and records the objects held by
+// the leaf.
+func (t *T) validateLeaf(n node.N, leaves map[id.ID]cid.ID, f func(c Check, x cid.ID, msg string, args ...any)) {
+	if n.Height() != 0 {
+		f(CheckHeight, n.ID(), "leaf node has height %v, want = 0", n.Height())
+	}
+
+	l := len(n.Leaves())
+	if l == 0 {
+		f(CheckLeafSize, n.ID(), "leaf node has no child objects")
+		return
+	}
+	if l > t.c.LeafSize() {
+		f(CheckLeafSize, n.ID(), "leaf node has %v child objects, want <= %v", l, t.c.LeafSize())
+	}
+
+	xs := make([]id.ID, 0, l)
+	for x := range n.Leaves() {
+		xs = append(xs, x)
+	}
+	sort.Slice(xs, func(i, j int) bool { return xs[i] < xs[j] })
+
+	for _, x := range xs {
+		if m, ok := leaves[x]; ok {
+			f(CheckObject, n.ID(), "object %v is also held by leaf %v", x, m)
+		}
+		leaves[x] = n.ID()
+
+		aabb, ok := t.data[x]
+		if !ok {
+			f(CheckObject, n.ID(), "leaf node holds unknown object %v", x)
+			continue
+		}
+		if m, ok := t.nodes[x]; ok && m != n.ID() {
+			f(CheckObject, n.ID(), "object %v is mapped to node %v", x, m)
+		}
+		if !hyperrectangle.Contains(n.AABB().R(), aabb) {
+			f(CheckAABB, n.ID(), "leaf AABB does not contain object %v", x)
+		}
+	}
+}
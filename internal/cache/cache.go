@@ -5,6 +5,8 @@ import (
 
 	"github.com/downflux/go-bvh/internal/cache/node"
 	"github.com/downflux/go-bvh/internal/cache/node/impl"
+	"github.com/downflux/go-bvh/internal/heuristic"
+	"github.com/downflux/go-geometry/nd/hyperrectangle"
 	"github.com/downflux/go-geometry/nd/vector"
 
 	cid "github.com/downflux/go-bvh/internal/cache/id"
@@ -13,6 +15,7 @@ import (
 type C struct {
 	k        vector.D
 	leafSize int
+	h        heuristic.F
 
 	data  []*impl.N
 	freed []cid.ID
@@ -21,6 +24,10 @@ type C struct {
 type O struct {
 	K        vector.D
 	LeafSize int
+
+	// Heuristic is the node heuristic used to calculate the cached node
+	// costs. If unset, this defaults to heuristic.H.
+	Heuristic heuristic.F
 }
 
 func New(o O) *C {
@@ -32,9 +39,15 @@ func New(o O) *C {
 		panic(fmt.Sprintf("invalid impl leaf size %v", o.LeafSize))
 	}
 
+	h := o.Heuristic
+	if h == nil {
+		h = heuristic.H
+	}
+
 	return &C{
 		k:        o.K,
 		leafSize: o.LeafSize,
+		h:        h,
 
 		data:  make([]*impl.N, 0, 1024),
 		freed: make([]cid.ID, 0, 1024),
@@ -44,6 +57,50 @@ func New(o O) *C {
 func (c *C) K() vector.D   { return c.k }
 func (c *C) LeafSize() int { return c.leafSize }
 
+// Clone returns a deep copy of the cache. The returned cache has identical node
+// IDs, links, and freed pool, and shares no memory with the source.
+func (c *C) Clone() *C {
+	d := &C{
+		k:        c.k,
+		leafSize: c.leafSize,
+		h:        c.h,
+
+		data:  make([]*impl.N, 0, cap(c.data)),
+		freed: make([]cid.ID, len(c.freed), cap(c.freed)),
+	}
+	copy(d.freed, c.freed)
+
+	for _, n := range c.data {
+		if n == nil {
+			d.data = append(d.data, nil)
+			continue
+		}
+
+		m := impl.New(d, n.ID())
+		d.data = append(d.data, m)
+
+		if !n.IsAllocated() {
+			continue
+		}
+
+		m.Allocate(n.ParentID(), n.LeftID(), n.RightID())
+		m.SetHeight(n.Height())
+		m.SetHeuristic(n.Heuristic())
+		m.AABB().Copy(n.AABB().R())
+		for x := range n.Leaves() {
+			m.Leaves()[x] = struct{}{}
+		}
+	}
+
+	return d
+}
+
+// Freed returns the number of nodes in the cache available pool.
+func (c *C) Freed() int { return len(c.freed) }
+
+// H calculates the node heuristic of the input AABB.
+func (c *C) H(r hyperrectangle.R) float64 { return c.h(r) }
+
 // IsAllocated checks if the given impl is tracked by the cache. This function
 // returns false if the impl is in the freed pool.
 func (c *C) IsAllocated(x cid.ID) bool {
@@ -61,6 +118,21 @@ func (c *C) Get(x cid.ID) (node.N, bool) {
 	return n, n.IsAllocated()
 }
 
+// Links returns the raw parent, left, and right child IDs of the input node.
+// Unlike the corresponding node.N accessors, the returned IDs are not checked
+// against the cache, and may therefore refer to freed nodes. This is useful
+// for debugging corrupted trees.
+func (c *C) Links(x cid.ID) (cid.ID, cid.ID, cid.ID, bool) {
+	if !x.IsValid() || int(x) >= len(c.data) {
+		return cid.IDInvalid, cid.IDInvalid, cid.IDInvalid, false
+	}
+	n := c.data[x]
+	if n == nil {
+		return cid.IDInvalid, cid.IDInvalid, cid.IDInvalid, true
+	}
+	return n.ParentID(), n.LeftID(), n.RightID(), true
+}
+
 func (c *C) GetOrDie(x cid.ID) node.N {
 	n, ok := c.Get(x)
 	if !ok {
@@ -86,6 +158,9 @@ func (c *C) Insert(p, l, r cid.ID, validate bool) cid.ID {
 	// Reuse a impl if available -- this avoids additional allocs.
 	if len(c.freed) > 0 {
 		x, c.freed = c.freed[len(c.freed)-1], c.freed[:len(c.freed)-1]
+		if c.data[x] == nil {
+			c.data[x] = impl.New(c, x)
+		}
 	} else {
 		x = cid.ID(len(c.data))
 		c.data = append(c.data, impl.New(c, x))
@@ -0,0 +1,145 @@
+package cache
+
+import (
+	"sort"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-bvh/internal/cache/node"
+	"github.com/downflux/go-bvh/internal/cache/node/impl"
+	"github.com/downflux/go-bvh/internal/codec"
+	"github.com/downflux/go-bvh/internal/heuristic"
+	"github.com/downflux/go-geometry/nd/vector"
+
+	cid "github.com/downflux/go-bvh/internal/cache/id"
+)
+
+// Encode appends the binary representation of the cache to the input buffer.
+// The full node layout is preserved, including the node IDs and the freed node
+// pool, so that a decoded cache will allocate nodes in the same order as the
+// original.
+//
+// The node heuristic function is not encoded.
+func (c *C) Encode(w *codec.W) {
+	w.Uint64(uint64(c.k))
+	w.Uint64(uint64(c.leafSize))
+
+	w.Uint64(uint64(len(c.data)))
+	for _, n := range c.data {
+		w.Bool(n.IsAllocated())
+		if !n.IsAllocated() {
+			continue
+		}
+
+		for _, m := range []node.N{n.Parent(), n.Left(), n.Right()} {
+			x := cid.IDInvalid
+			if m != nil {
+				x = m.ID()
+			}
+			w.Int64(int64(x))
+		}
+		w.Int64(int64(n.Height()))
+		w.Float64(n.Heuristic())
+		w.Float64s(n.AABB().Min())
+		w.Float64s(n.AABB().Max())
+
+		// Sort the leaves to ensure the output is deterministic.
+		xs := make([]id.ID, 0, len(n.Leaves()))
+		for x := range n.Leaves() {
+			xs = append(xs, x)
+		}
+		sort.Slice(xs, func(i, j int) bool { return xs[i] < xs[j] })
+
+		w.Uint64(uint64(len(xs)))
+		for _, x := range xs {
+			w.Uint64(uint64(x))
+		}
+	}
+
+	w.Uint64(uint64(len(c.freed)))
+	for _, x := range c.freed {
+		w.Int64(int64(x))
+	}
+}
+
+// Decode reads a cache previously written by Encode. The decoded cache will
+// use the input node heuristic, which should match the heuristic of the
+// original cache.
+//
+// Decode checks that the node links refer to existing nodes, but does not
+// validate the structure of the tree itself. Freed nodes are not allocated
+// until reused by Insert.
+func Decode(r *codec.R, h heuristic.F) *C {
+	k := vector.D(r.Uint64())
+	size := int(r.Uint64())
+	if r.Err() != nil {
+		return nil
+	}
+	if k <= 0 || size <= 0 {
+		r.Errorf("invalid cache dimensions K = %v, LeafSize = %v", k, size)
+		return nil
+	}
+
+	c := New(O{
+		K:         k,
+		LeafSize:  size,
+		Heuristic: h,
+	})
+
+	n := r.Count(1)
+	c.data = make([]*impl.N, 0, n)
+
+	link := func(x cid.ID) cid.ID {
+		if x != cid.IDInvalid && (x < 0 || int(x) >= n) {
+			r.Errorf("invalid node link %v", x)
+			return cid.IDInvalid
+		}
+		return x
+	}
+
+	for i := 0; i < n && r.Err() == nil; i++ {
+		// Freed nodes are lazily created on reuse, which ensures the
+		// decoded cache size is proportional to the input.
+		if !r.Bool() {
+			c.data = append(c.data, nil)
+			continue
+		}
+
+		// Guard against large allocations from a corrupted dimension,
+		// as each allocated node encodes its AABB in 16K bytes.
+		if uint64(k) > uint64(r.Len())/16 {
+			r.Errorf("invalid cache dimension K = %v", k)
+			break
+		}
+
+		m := impl.New(c, cid.ID(i))
+		c.data = append(c.data, m)
+
+		p := link(cid.ID(r.Int64()))
+		lc := link(cid.ID(r.Int64()))
+		rc := link(cid.ID(r.Int64()))
+		m.Allocate(p, lc, rc)
+
+		m.SetHeight(int(r.Int64()))
+		m.SetHeuristic(r.Float64())
+		r.Float64s(m.AABB().Min())
+		r.Float64s(m.AABB().Max())
+
+		for j, l := 0, r.Count(8); j < l; j++ {
+			m.Leaves()[id.ID(r.Uint64())] = struct{}{}
+		}
+	}
+
+	for i, l := 0, r.Count(8); i < l; i++ {
+		x := cid.ID(r.Int64())
+		if x < 0 || int(x) >= len(c.data) || c.data[x].IsAllocated() {
+			r.Errorf("invalid freed node %v", x)
+			break
+		}
+		c.freed = append(c.freed, x)
+	}
+
+	if r.Err() != nil {
+		return nil
+	}
+	return c
+}
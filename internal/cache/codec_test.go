@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/downflux/go-bvh/internal/cache/node"
+	"github.com/downflux/go-bvh/internal/codec"
+	"github.com/downflux/go-geometry/nd/hyperrectangle"
+	"github.com/downflux/go-geometry/nd/vector"
+
+	cid "github.com/downflux/go-bvh/internal/cache/id"
+	ncmp "github.com/downflux/go-bvh/internal/cache/node/util/cmp"
+)
+
+func TestCodec(t *testing.T) {
+	c := New(O{
+		K:        2,
+		LeafSize: 2,
+	})
+
+	root := c.GetOrDie(c.Insert(cid.IDInvalid, cid.IDInvalid, cid.IDInvalid, true))
+	l := c.GetOrDie(c.Insert(root.ID(), cid.IDInvalid, cid.IDInvalid, true))
+	r := c.GetOrDie(c.Insert(root.ID(), cid.IDInvalid, cid.IDInvalid, true))
+	root.SetLeft(l.ID())
+	root.SetRight(r.ID())
+	root.SetHeight(1)
+
+	l.Leaves()[100] = struct{}{}
+	l.Leaves()[101] = struct{}{}
+	r.Leaves()[102] = struct{}{}
+
+	l.AABB().Copy(*hyperrectangle.New(vector.V{0, 0}, vector.V{1, 1}))
+	r.AABB().Copy(*hyperrectangle.New(vector.V{2, 2}, vector.V{3, 3}))
+	root.AABB().Copy(*hyperrectangle.New(vector.V{0, 0}, vector.V{3, 3}))
+	for _, n := range []node.N{root, l, r} {
+		n.SetHeuristic(1)
+	}
+
+	// Ensure the freed pool is preserved.
+	c.DeleteOrDie(c.Insert(cid.IDInvalid, cid.IDInvalid, cid.IDInvalid, true))
+
+	w := codec.NewW(0)
+	c.Encode(w)
+
+	r0 := codec.NewR(w.Bytes())
+	got := Decode(r0, nil)
+	if err := r0.Err(); err != nil {
+		t.Fatalf("Decode() = %v, want = nil", err)
+	}
+
+	if !ncmp.Equal(root, got.GetOrDie(root.ID())) {
+		t.Errorf("Decode() mismatch: got = %v, want = %v", got.GetOrDie(root.ID()), root)
+	}
+
+	// Check that freed nodes are preserved by Clone, even though they are
+	// not allocated until reuse.
+	got = got.Clone()
+	if want, x := c.Insert(cid.IDInvalid, cid.IDInvalid, cid.IDInvalid, true), got.Insert(cid.IDInvalid, cid.IDInvalid, cid.IDInvalid, true); x != want {
+		t.Errorf("Insert() after Decode() does not reuse the freed node %v", want)
+	} else if d := got.GetOrDie(x).AABB().Min().Dimension(); d != 2 {
+		t.Errorf("Insert() after Decode() returned a node with dimension %v, want = %v", d, 2)
+	}
+
+	t.Run("Dimension", func(t *testing.T) {
+		b := append([]byte{}, w.Bytes()...)
+		b[7] = 0x40
+
+		r := codec.NewR(b)
+		if Decode(r, nil); r.Err() == nil {
+			t.Errorf("Decode() = nil, want a non-nil error for a corrupted dimension")
+		}
+	})
+
+	t.Run("Truncated", func(t *testing.T) {
+		for i := 0; i < len(w.Bytes()); i++ {
+			r := codec.NewR(w.Bytes()[:i])
+			if Decode(r, nil); r.Err() == nil {
+				t.Fatalf("Decode() = nil, want a non-nil error for a buffer of length %v", i)
+			}
+		}
+	})
+}
@@ -15,6 +15,10 @@ import (
 	cid "github.com/downflux/go-bvh/internal/cache/id"
 )
 
+// leafSizeHint caps the initial capacity of the node leaf set, so that a large
+// leaf size does not force a large allocation for every node.
+const leafSizeHint = 64
+
 const (
 	idSelf int = iota
 	idParent
@@ -29,6 +33,7 @@ type A interface {
 	Get(x cid.ID) (node.N, bool)
 	LeafSize() int
 	K() vector.D
+	H(r hyperrectangle.R) float64
 }
 
 // N is a pure data struct representing a BVH tree node. This data struct is
@@ -75,13 +80,18 @@ type N struct {
 }
 
 func New(a A, x cid.ID) *N {
+	hint := a.LeafSize()
+	if hint > leafSizeHint {
+		hint = leafSizeHint
+	}
+
 	return &N{
 		cache: a,
 		aabbCache: hyperrectangle.New(
 			vector.V(make([]float64, a.K())),
 			vector.V(make([]float64, a.K())),
 		).M(),
-		dataCache: make(map[id.ID]struct{}, a.LeafSize()),
+		dataCache: make(map[id.ID]struct{}, hint),
 		ids: [4]cid.ID{
 			/* idSelf = */ x,
 			/* idParent = */ cid.IDInvalid,
@@ -133,6 +143,8 @@ func (n *N) IsRoot() bool {
 	return !ok
 }
 
+func (n *N) H(r hyperrectangle.R) float64 { return n.cache.H(r) }
+
 func (n *N) Heuristic() float64     { return n.heuristicCache }
 func (n *N) SetHeuristic(a float64) { n.heuristicCache = a }
 func (n *N) Height() int            { return n.heightCache }
@@ -247,6 +259,12 @@ func (n *N) Right() node.N {
 	return nil
 }
 
+// ParentID, LeftID, and RightID return the raw neighbor IDs of the node. These
+// IDs are not validated against the cache.
+func (n *N) ParentID() cid.ID { return n.ids[idParent] }
+func (n *N) LeftID() cid.ID   { return n.ids[idLeft] }
+func (n *N) RightID() cid.ID  { return n.ids[idRight] }
+
 func (n *N) SetParent(x cid.ID) { n.ids[idParent] = x }
 func (n *N) SetLeft(x cid.ID)   { n.SetChild(branch.BLeft, x) }
 func (n *N) SetRight(x cid.ID)  { n.SetChild(branch.BRight, x) }
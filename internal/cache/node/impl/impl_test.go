@@ -5,6 +5,7 @@ import (
 
 	"github.com/downflux/go-bvh/internal/cache/node"
 	"github.com/downflux/go-bvh/internal/cache/node/util/cmp"
+	"github.com/downflux/go-bvh/internal/heuristic"
 	"github.com/downflux/go-geometry/nd/hyperrectangle"
 	"github.com/downflux/go-geometry/nd/vector"
 
@@ -23,6 +24,8 @@ type MockCache struct {
 func (m *MockCache) K() vector.D   { return vector.D(2) }
 func (m *MockCache) LeafSize() int { return 1 }
 
+func (m *MockCache) H(r hyperrectangle.R) float64 { return heuristic.H(r) }
+
 func (m *MockCache) Get(x cid.ID) (node.N, bool) {
 	n, ok := m.data[x]
 	return n, ok
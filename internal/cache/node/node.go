@@ -8,7 +8,6 @@ import (
 
 	"github.com/downflux/go-bvh/id"
 	"github.com/downflux/go-bvh/internal/cache/branch"
-	"github.com/downflux/go-bvh/internal/heuristic"
 	"github.com/downflux/go-geometry/nd/hyperrectangle"
 	"github.com/downflux/go-geometry/nd/vector"
 
@@ -29,6 +28,10 @@ type N interface {
 
 	AABB() hyperrectangle.M
 
+	// H calculates the node heuristic of an arbitrary AABB, as defined by
+	// the tree which contains this node.
+	H(r hyperrectangle.R) float64
+
 	Heuristic() float64
 	SetHeuristic(a float64)
 
@@ -82,10 +85,10 @@ func Union(data map[id.ID]hyperrectangle.R, xs ...id.ID) hyperrectangle.R {
 
 // SetAABB updates a node's AABB with the bounding boxes of its children. For a
 // leaf node, this bounding box will have a buffer of some given expansion
-// factor.
+// factor, unless all objects in the leaf are in the input static set.
 //
 // The input node must be valid and up-to-date.
-func SetAABB(n N, data map[id.ID]hyperrectangle.R, tolerance float64) {
+func SetAABB(n N, data map[id.ID]hyperrectangle.R, static map[id.ID]struct{}, tolerance float64) {
 	if tolerance < 1 {
 		panic(fmt.Sprintf("cannot set expansion factor to be less than the AABB size"))
 	}
@@ -95,7 +98,7 @@ func SetAABB(n N, data map[id.ID]hyperrectangle.R, tolerance float64) {
 	if !n.IsLeaf() {
 		target.Copy(n.Left().AABB().R())
 		target.Union(n.Right().AABB().R())
-		n.SetHeuristic(heuristic.H(target.R()))
+		n.SetHeuristic(n.H(target.R()))
 		return
 	}
 
@@ -107,6 +110,20 @@ func SetAABB(n N, data map[id.ID]hyperrectangle.R, tolerance float64) {
 	target.Copy(Union(data, xs...))
 	k := target.Min().Dimension()
 
+	// Leaves which only contain static objects are not expected to change,
+	// and so do not need a buffer.
+	fixed := true
+	for _, x := range xs {
+		if _, ok := static[x]; !ok {
+			fixed = false
+			break
+		}
+	}
+	if fixed {
+		n.SetHeuristic(n.H(target.R()))
+		return
+	}
+
 	epsilon := math.Pow(tolerance, 1/float64(k))
 	tmin, tmax := target.Min(), target.Max()
 	for i := vector.D(0); i < k; i++ {
@@ -115,5 +132,5 @@ func SetAABB(n N, data map[id.ID]hyperrectangle.R, tolerance float64) {
 		tmin[i] = tmin[i] - offset
 		tmax[i] = tmax[i] + offset
 	}
-	n.SetHeuristic(heuristic.H(target.R()))
+	n.SetHeuristic(n.H(target.R()))
 }
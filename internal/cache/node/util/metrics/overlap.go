@@ -0,0 +1,147 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-bvh/internal/cache/node"
+	"github.com/downflux/go-bvh/internal/cache/node/util"
+	"github.com/downflux/go-geometry/nd/hyperrectangle"
+
+	cid "github.com/downflux/go-bvh/internal/cache/id"
+)
+
+func EPO(n node.N, data map[id.ID]hyperrectangle.R) float64 { return AilaC.EPO(n, data) }
+
+// EPO returns the effective primitive overlap of the tree, as defined in Aila
+// et al. 2013. The EPO of a tree is
+//
+//	Σ C(n) * SA(Q(n)) / SA(root)
+//
+// Here, Q(n) is the union of the parts of the objects which are not in the
+// subtree rooted at n but which still overlap the AABB of n, and C(n) is
+// either c.Internal or c.Leaf. That is, EPO measures how much of the tree will
+// be needlessly traversed when querying for objects.
+//
+// As the objects here are AABBs rather than triangles, we approximate the
+// surface area of the union as the sum of the surface areas of the individual
+// (clipped) object AABBs.
+//
+// N.B.: This is a quadratic operation in the worst case.
+func (c C) EPO(n node.N, data map[id.ID]hyperrectangle.R) float64 {
+	var h float64
+
+	// Sum the overlaps in a fixed order to ensure the result is
+	// deterministic. As each leaf may be visited once per node, the leaf
+	// objects are sorted ahead of time.
+	leaves := make(map[cid.ID][]id.ID)
+	util.PreOrder(n, func(m node.N) {
+		if !m.IsLeaf() {
+			return
+		}
+		xs := make([]id.ID, 0, len(m.Leaves()))
+		for x := range m.Leaves() {
+			xs = append(xs, x)
+		}
+		sort.Slice(xs, func(i, j int) bool { return xs[i] < xs[j] })
+		leaves[m.ID()] = xs
+	})
+
+	open := make([]node.N, 0, 128)
+	util.PreOrder(n, func(m node.N) {
+		var a float64
+
+		open = append(open[:0], n)
+
+		var s node.N
+		for len(open) > 0 {
+			s, open = open[len(open)-1], open[:len(open)-1]
+
+			// Skip the subtree rooted at m.
+			if s.ID() == m.ID() || hyperrectangle.Disjoint(s.AABB().R(), m.AABB().R()) {
+				continue
+			}
+			if s.IsLeaf() {
+				for _, x := range leaves[s.ID()] {
+					if r, ok := hyperrectangle.Intersect(data[x], m.AABB().R()); ok {
+						a += SA(r)
+					}
+				}
+			} else {
+				open = append(open, s.Left(), s.Right())
+			}
+		}
+
+		if m.IsLeaf() {
+			h += c.Leaf * a
+		} else {
+			h += c.Internal * a
+		}
+	})
+	return h / SA(n.AABB().R())
+}
+
+// LCV returns the leaf count variability of the tree, as defined in Aila et al.
+// 2013, i.e. the standard deviation of the number of leaf nodes visited over
+// the input set of queries. As with the query ops, a query will only visit the
+// children of a node if the node AABB passes the query filter.
+func LCV(n node.N, qs []func(r hyperrectangle.R) bool) float64 {
+	if len(qs) == 0 {
+		return 0
+	}
+
+	counts := make([]float64, 0, len(qs))
+	open := make([]node.N, 0, 128)
+
+	for _, q := range qs {
+		var k int
+
+		open = append(open[:0], n)
+
+		var m node.N
+		for len(open) > 0 {
+			m, open = open[len(open)-1], open[:len(open)-1]
+			if !q(m.AABB().R()) {
+				continue
+			}
+			if m.IsLeaf() {
+				k++
+			} else {
+				open = append(open, m.Left(), m.Right())
+			}
+		}
+		counts = append(counts, float64(k))
+	}
+
+	var mean float64
+	for _, k := range counts {
+		mean += k
+	}
+	mean /= float64(len(counts))
+
+	var v float64
+	for _, k := range counts {
+		v += (k - mean) * (k - mean)
+	}
+	return math.Sqrt(v / float64(len(counts)))
+}
+
+// Margin returns the total volume of the buffer added to the leaf AABBs, i.e.
+// the difference between the volume of each leaf AABB and the volume of the
+// tightest AABB which bounds the leaf objects.
+func Margin(n node.N, data map[id.ID]hyperrectangle.R) float64 {
+	var v float64
+	util.PreOrder(n, func(m node.N) {
+		if !m.IsLeaf() {
+			return
+		}
+
+		xs := make([]id.ID, 0, len(m.Leaves()))
+		for x := range m.Leaves() {
+			xs = append(xs, x)
+		}
+		v += hyperrectangle.V(m.AABB().R()) - hyperrectangle.V(node.Union(data, xs...))
+	})
+	return v
+}
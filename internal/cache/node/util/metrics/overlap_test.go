@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-bvh/internal/cache"
+	"github.com/downflux/go-bvh/internal/cache/node"
+	"github.com/downflux/go-geometry/epsilon"
+	"github.com/downflux/go-geometry/nd/hyperrectangle"
+	"github.com/downflux/go-geometry/nd/vector"
+
+	cid "github.com/downflux/go-bvh/internal/cache/id"
+)
+
+// tree generates a two-leaf tree, where each leaf contains a single object.
+func tree(l, r hyperrectangle.R, tolerance float64) (node.N, map[id.ID]hyperrectangle.R) {
+	data := map[id.ID]hyperrectangle.R{
+		100: l,
+		101: r,
+	}
+
+	c := cache.New(cache.O{
+		LeafSize: 1,
+		K:        2,
+	})
+
+	root := c.GetOrDie(c.Insert(cid.IDInvalid, cid.IDInvalid, cid.IDInvalid, true))
+	nl := c.GetOrDie(c.Insert(root.ID(), cid.IDInvalid, cid.IDInvalid, true))
+	nr := c.GetOrDie(c.Insert(root.ID(), cid.IDInvalid, cid.IDInvalid, true))
+	root.SetLeft(nl.ID())
+	root.SetRight(nr.ID())
+
+	nl.Leaves()[100] = struct{}{}
+	nr.Leaves()[101] = struct{}{}
+
+	node.SetAABB(nl, data, nil, tolerance)
+	node.SetAABB(nr, data, nil, tolerance)
+	node.SetAABB(root, nil, nil, 1)
+	return root, data
+}
+
+func TestEPO(t *testing.T) {
+	type config struct {
+		name string
+		l, r hyperrectangle.R
+		want float64
+	}
+
+	configs := []config{
+		{
+			name: "Disjoint",
+			l:    *hyperrectangle.New(vector.V{0, 0}, vector.V{1, 1}),
+			r:    *hyperrectangle.New(vector.V{2, 0}, vector.V{3, 1}),
+			want: 0,
+		},
+		{
+			// The right object overlaps the left leaf in the
+			// region [1, 2] x [0, 2], and vice versa.
+			name: "Overlap",
+			l:    *hyperrectangle.New(vector.V{0, 0}, vector.V{2, 2}),
+			r:    *hyperrectangle.New(vector.V{1, 0}, vector.V{3, 2}),
+			want: 2 * SA(*hyperrectangle.New(vector.V{1, 0}, vector.V{2, 2})) / SA(*hyperrectangle.New(vector.V{0, 0}, vector.V{3, 2})),
+		},
+	}
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			root, data := tree(c.l, c.r, 1)
+			if got := EPO(root, data); !epsilon.Within(got, c.want) {
+				t.Errorf("EPO() = %v, want = %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestLCV(t *testing.T) {
+	root, _ := tree(
+		*hyperrectangle.New(vector.V{0, 0}, vector.V{1, 1}),
+		*hyperrectangle.New(vector.V{2, 0}, vector.V{3, 1}),
+		1,
+	)
+
+	// One query hits both leaves, and the other hits a single leaf.
+	qs := []func(r hyperrectangle.R) bool{
+		func(r hyperrectangle.R) bool { return true },
+		func(r hyperrectangle.R) bool { return r.Min().X(0) < 2 },
+	}
+	if got, want := LCV(root, qs), 0.5; !epsilon.Within(got, want) {
+		t.Errorf("LCV() = %v, want = %v", got, want)
+	}
+}
+
+func TestMargin(t *testing.T) {
+	root, data := tree(
+		*hyperrectangle.New(vector.V{0, 0}, vector.V{1, 1}),
+		*hyperrectangle.New(vector.V{2, 0}, vector.V{3, 1}),
+		4,
+	)
+
+	// Each leaf AABB is scaled by a factor of 4 in volume.
+	if got, want := Margin(root, data), 6.0; !epsilon.Within(got, want) {
+		t.Errorf("Margin() = %v, want = %v", got, want)
+	}
+}
@@ -38,9 +38,17 @@ type C struct {
 //
 // Per Aila et al., a "normal" SAH value is around 100.
 //
+// The cost of a degenerate subtree whose root has no surface area (e.g. a tree
+// of coincident points) is zero.
+//
 // N.B.: SAH assumes the local subtree has up-to-date AABB bounding boxes and
 // heuristic caches.
 func (c C) SAH(n node.N) float64 {
+	a := SA(n.AABB().R())
+	if a == 0 {
+		return 0
+	}
+
 	var ci, cl, co float64
 	util.PreOrder(n, func(n node.N) {
 		if !n.IsLeaf() {
@@ -50,5 +58,5 @@ func (c C) SAH(n node.N) float64 {
 			co += SA(n.AABB().R()) * float64(len(n.Leaves()))
 		}
 	})
-	return (c.Internal*ci + c.Leaf*cl + c.Object*co) / SA(n.AABB().R())
+	return (c.Internal*ci + c.Leaf*cl + c.Object*co) / a
 }
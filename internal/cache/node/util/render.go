@@ -0,0 +1,145 @@
+package util
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-bvh/internal/cache/node"
+	"github.com/downflux/go-geometry/nd/hyperrectangle"
+)
+
+func leaves(n node.N) []id.ID {
+	xs := make([]id.ID, 0, len(n.Leaves()))
+	for x := range n.Leaves() {
+		xs = append(xs, x)
+	}
+	sort.Slice(xs, func(i, j int) bool { return xs[i] < xs[j] })
+	return xs
+}
+
+// DOT writes the subtree rooted at n as a Graphviz DOT digraph. Each node is
+// labeled with its ID, height, and cached heuristic; leaf nodes additionally
+// list the objects they contain. A nil input node generates an empty graph.
+func DOT(w io.Writer, n node.N) error {
+	var b strings.Builder
+
+	b.WriteString("digraph BVH {\n")
+	b.WriteString("\tnode [shape=box, fontname=monospace];\n")
+
+	if n != nil {
+		PreOrder(n, func(n node.N) {
+			label := fmt.Sprintf(
+				"ID: %v\\nHeight: %v\\nHeuristic: %.4g",
+				n.ID(),
+				n.Height(),
+				n.Heuristic(),
+			)
+			if n.IsLeaf() {
+				xs := []string{}
+				for _, x := range leaves(n) {
+					xs = append(xs, fmt.Sprint(x))
+				}
+				label += fmt.Sprintf("\\nData: %v", strings.Join(xs, ", "))
+				fmt.Fprintf(&b, "\tn%v [label=\"%v\", style=filled, fillcolor=lightgrey];\n", n.ID(), label)
+			} else {
+				fmt.Fprintf(&b, "\tn%v [label=\"%v\"];\n", n.ID(), label)
+				fmt.Fprintf(&b, "\tn%v -> n%v;\n", n.ID(), n.Left().ID())
+				fmt.Fprintf(&b, "\tn%v -> n%v;\n", n.ID(), n.Right().ID())
+			}
+		})
+	}
+
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// SVG draws the subtree rooted at n as an SVG image. Each node AABB is drawn as
+// an outline, colored by the depth of the node relative to n, and the object
+// AABBs tracked by the leaves are overlaid as filled boxes. A nil input node
+// generates an empty image.
+//
+// The SVG y-axis points downwards; the image is flipped so that the y-axis of
+// the tree points upwards.
+//
+// SVG only supports trees with K = 2.
+func SVG(w io.Writer, n node.N, data map[id.ID]hyperrectangle.R) error {
+	var b strings.Builder
+
+	if n == nil {
+		b.WriteString(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 1 1"></svg>` + "\n")
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+
+	if k := n.AABB().Min().Dimension(); k != 2 {
+		return fmt.Errorf("cannot render a tree of dimension %v as an SVG", k)
+	}
+
+	rmin, rmax := n.AABB().Min(), n.AABB().Max()
+	dx, dy := rmax[0]-rmin[0], rmax[1]-rmin[1]
+
+	// Add a margin around the root AABB so that the outline is visible.
+	margin := 0.02 * math.Max(math.Max(dx, dy), 1e-9)
+
+	fmt.Fprintf(
+		&b,
+		"<svg xmlns=\"http://www.w3.org/2000/svg\" viewBox=\"%v %v %v %v\">\n",
+		rmin[0]-margin,
+		-rmax[1]-margin,
+		dx+2*margin,
+		dy+2*margin,
+	)
+
+	rect := func(r hyperrectangle.R, style string) {
+		fmt.Fprintf(
+			&b,
+			"\t<rect x=\"%v\" y=\"%v\" width=\"%v\" height=\"%v\" vector-effect=\"non-scaling-stroke\" %v/>\n",
+			r.Min()[0],
+			-r.Max()[1],
+			r.Max()[0]-r.Min()[0],
+			r.Max()[1]-r.Min()[1],
+			style,
+		)
+	}
+
+	type element struct {
+		n     node.N
+		depth int
+	}
+	open := []element{{n: n, depth: 0}}
+
+	var objects []id.ID
+	for len(open) > 0 {
+		var e element
+		e, open = open[0], open[1:]
+
+		rect(e.n.AABB().R(), fmt.Sprintf(
+			"fill=\"none\" stroke=\"hsl(%v, 70%%, 45%%)\" stroke-width=\"1\"",
+			(e.depth*47)%360,
+		))
+
+		if e.n.IsLeaf() {
+			objects = append(objects, leaves(e.n)...)
+		} else {
+			open = append(open,
+				element{n: e.n.Left(), depth: e.depth + 1},
+				element{n: e.n.Right(), depth: e.depth + 1},
+			)
+		}
+	}
+
+	for _, x := range objects {
+		rect(data[x], "fill=\"black\" fill-opacity=\"0.25\" stroke=\"none\"")
+	}
+
+	b.WriteString("</svg>\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,101 @@
+package util
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-bvh/internal/cache"
+	"github.com/downflux/go-bvh/internal/cache/node"
+	"github.com/downflux/go-geometry/nd/hyperrectangle"
+	"github.com/downflux/go-geometry/nd/vector"
+	"github.com/google/go-cmp/cmp"
+
+	cid "github.com/downflux/go-bvh/internal/cache/id"
+)
+
+func tree(k vector.D) (node.N, map[id.ID]hyperrectangle.R) {
+	c := cache.New(cache.O{
+		LeafSize: 2,
+		K:        k,
+	})
+
+	data := map[id.ID]hyperrectangle.R{}
+	for i, x := range []id.ID{100, 101, 102} {
+		min := vector.V(make([]float64, k))
+		max := vector.V(make([]float64, k))
+		for j := vector.D(0); j < k; j++ {
+			min[j] = float64(2 * i)
+			max[j] = float64(2*i + 1)
+		}
+		data[x] = *hyperrectangle.New(min, max)
+	}
+
+	root := c.GetOrDie(c.Insert(cid.IDInvalid, cid.IDInvalid, cid.IDInvalid, true))
+	l := c.GetOrDie(c.Insert(root.ID(), cid.IDInvalid, cid.IDInvalid, true))
+	r := c.GetOrDie(c.Insert(root.ID(), cid.IDInvalid, cid.IDInvalid, true))
+	root.SetLeft(l.ID())
+	root.SetRight(r.ID())
+
+	l.Leaves()[101] = struct{}{}
+	l.Leaves()[100] = struct{}{}
+	r.Leaves()[102] = struct{}{}
+
+	for _, n := range []node.N{l, r, root} {
+		node.SetAABB(n, data, nil, 1)
+		node.SetHeight(n)
+	}
+
+	return root, data
+}
+
+func TestDOT(t *testing.T) {
+	root, _ := tree(2)
+
+	var b strings.Builder
+	if err := DOT(&b, root); err != nil {
+		t.Fatalf("DOT() = %v, want = nil", err)
+	}
+
+	want := strings.Join([]string{
+		`digraph BVH {`,
+		`	node [shape=box, fontname=monospace];`,
+		`	n0 [label="ID: 0\nHeight: 1\nHeuristic: 20"];`,
+		`	n0 -> n1;`,
+		`	n0 -> n2;`,
+		`	n1 [label="ID: 1\nHeight: 0\nHeuristic: 12\nData: 100, 101", style=filled, fillcolor=lightgrey];`,
+		`	n2 [label="ID: 2\nHeight: 0\nHeuristic: 4\nData: 102", style=filled, fillcolor=lightgrey];`,
+		`}`,
+		``,
+	}, "\n")
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("DOT() mismatch (-want +got):\n%v", diff)
+	}
+}
+
+func TestSVG(t *testing.T) {
+	t.Run("K=2", func(t *testing.T) {
+		root, data := tree(2)
+
+		var b strings.Builder
+		if err := SVG(&b, root, data); err != nil {
+			t.Fatalf("SVG() = %v, want = nil", err)
+		}
+
+		// Expect one rectangle per node and one per object.
+		if got, want := strings.Count(b.String(), "<rect"), 6; got != want {
+			t.Errorf("SVG() generated %v rectangles, want = %v", got, want)
+		}
+		if !strings.HasPrefix(b.String(), "<svg") || !strings.HasSuffix(b.String(), "</svg>\n") {
+			t.Errorf("SVG() = %v, want a well-formed SVG document", b.String())
+		}
+	})
+	t.Run("K=3", func(t *testing.T) {
+		root, data := tree(3)
+
+		var b strings.Builder
+		if err := SVG(&b, root, data); err == nil {
+			t.Errorf("SVG() = nil, want a non-nil error")
+		}
+	})
+}
@@ -14,8 +14,8 @@ import (
 //	   / \
 //	  b   y
 //
-// where H(y) > H(b), this function will swap a and y so that the resultant tree
-// looks like
+// where H(y) > H(b), or H(y) = H(b) and H(z) = H(a) + 2, this function will swap
+// a and y so that the resultant tree looks like
 //
 //	  x
 //	 / \
@@ -27,7 +27,7 @@ import (
 // local tree was previously balanced (before the subtree rooted at x was
 // mutated through an insert or delete operation). Because of this assumption,
 // the maximum possible imbalance between the children of x (i.e. a and z) is
-// two.
+// two. The case where H(y) = H(b) only arises after a delete operation.
 //
 // Further note that the rebalance operation is simplified because a BVH tree
 // is invariant under sibling swaps --
@@ -71,6 +71,8 @@ func AVL(x node.N) node.N {
 			// sb is the shallower node by construction.
 			sb, sy = sy, sb
 			a, y = sa, sy
+		} else if sa.Height()+1 < sz.Height() {
+			a, y = sa, sy
 		}
 	} else if sz.Height() < sa.Height() {
 		// sa is the shallower node by construction.
@@ -80,6 +82,8 @@ func AVL(x node.N) node.N {
 		} else if sy.Height() < sb.Height() {
 			sb, sy = sy, sb
 			a, y = sa, sy
+		} else if sa.Height()+1 < sz.Height() {
+			a, y = sa, sy
 		}
 	}
 	if a != nil && y != nil {
@@ -90,7 +94,7 @@ func AVL(x node.N) node.N {
 		// swapped, the relative depth order is reversed. See the
 		// function docstring for the expected subtree layout after
 		// swap.
-		node.SetAABB(a.Parent(), nil, 1)
+		node.SetAABB(a.Parent(), nil, nil, 1)
 		node.SetHeight(a.Parent())
 
 		// The AABB of the local root has not changed, so we do not need to
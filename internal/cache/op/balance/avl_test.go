@@ -45,13 +45,13 @@ func TestAVL(t *testing.T) {
 			))
 			root.Leaves()[100] = struct{}{}
 			node.SetHeight(root)
-			node.SetAABB(root, data, 1)
+			node.SetAABB(root, data, nil, 1)
 
 			want := impl.New(c, root.ID())
 			want.Allocate(cid.IDInvalid, cid.IDInvalid, cid.IDInvalid)
 			want.Leaves()[100] = struct{}{}
 			node.SetHeight(want)
-			node.SetAABB(want, data, 1)
+			node.SetAABB(want, data, nil, 1)
 
 			return config{
 				name: "NoOp/Height=0",
@@ -128,7 +128,7 @@ func TestAVL(t *testing.T) {
 			ng.Leaves()[103] = struct{}{}
 
 			for _, n := range []node.N{ng, nf, ne, nd, nc, nb, na} {
-				node.SetAABB(n, data, 1)
+				node.SetAABB(n, data, nil, 1)
 				node.SetHeight(n)
 			}
 
@@ -155,7 +155,7 @@ func TestAVL(t *testing.T) {
 			wg.Leaves()[103] = struct{}{}
 
 			for _, n := range []node.N{wg, wf, wd, wb, we, wc, wa} {
-				node.SetAABB(n, data, 1)
+				node.SetAABB(n, data, nil, 1)
 				node.SetHeight(n)
 			}
 
@@ -165,6 +165,103 @@ func TestAVL(t *testing.T) {
 				want: wa,
 			}
 		}(),
+		// After a delete, the grandchildren of the root may have equal
+		// heights.
+		//
+		//    A
+		//   / \
+		//  B   C
+		//     / \
+		//    D   E
+		//   / \ / \
+		//  F  G H  I
+		//
+		// to
+		//
+		//    A
+		//   / \
+		//  E   C
+		//     / \
+		//    D   B
+		func() config {
+			data := map[id.ID]hyperrectangle.R{
+				// B
+				100: *hyperrectangle.New(vector.V{1, 1}, vector.V{2, 2}),
+				// F
+				101: *hyperrectangle.New(vector.V{2, 2}, vector.V{4, 4}),
+				// G
+				102: *hyperrectangle.New(vector.V{4, 4}, vector.V{8, 8}),
+				// H
+				103: *hyperrectangle.New(vector.V{8, 8}, vector.V{16, 16}),
+				// I
+				104: *hyperrectangle.New(vector.V{16, 16}, vector.V{32, 32}),
+			}
+
+			c := cache.New(cache.O{
+				K:        2,
+				LeafSize: 1,
+			})
+			na := c.GetOrDie(c.Insert(cid.IDInvalid, cid.IDInvalid, cid.IDInvalid, true))
+			nb := c.GetOrDie(c.Insert(na.ID(), cid.IDInvalid, cid.IDInvalid, true))
+			nc := c.GetOrDie(c.Insert(na.ID(), cid.IDInvalid, cid.IDInvalid, true))
+			nd := c.GetOrDie(c.Insert(nc.ID(), cid.IDInvalid, cid.IDInvalid, true))
+			ne := c.GetOrDie(c.Insert(nc.ID(), cid.IDInvalid, cid.IDInvalid, true))
+			nf := c.GetOrDie(c.Insert(nd.ID(), cid.IDInvalid, cid.IDInvalid, true))
+			ng := c.GetOrDie(c.Insert(nd.ID(), cid.IDInvalid, cid.IDInvalid, true))
+			nh := c.GetOrDie(c.Insert(ne.ID(), cid.IDInvalid, cid.IDInvalid, true))
+			ni := c.GetOrDie(c.Insert(ne.ID(), cid.IDInvalid, cid.IDInvalid, true))
+
+			na.SetLeft(nb.ID())
+			na.SetRight(nc.ID())
+			nc.SetLeft(nd.ID())
+			nc.SetRight(ne.ID())
+			nd.SetLeft(nf.ID())
+			nd.SetRight(ng.ID())
+			ne.SetLeft(nh.ID())
+			ne.SetRight(ni.ID())
+
+			nb.Leaves()[100] = struct{}{}
+			nf.Leaves()[101] = struct{}{}
+			ng.Leaves()[102] = struct{}{}
+			nh.Leaves()[103] = struct{}{}
+			ni.Leaves()[104] = struct{}{}
+
+			for _, n := range []node.N{ni, nh, ng, nf, ne, nd, nc, nb, na} {
+				node.SetAABB(n, data, nil, 1)
+				node.SetHeight(n)
+			}
+
+			d := cache.New(cache.O{
+				K:        2,
+				LeafSize: 1,
+			})
+			wa := d.GetOrDie(d.Insert(cid.IDInvalid, ne.ID(), nc.ID(), false))
+			wb := d.GetOrDie(d.Insert(nc.ID(), cid.IDInvalid, cid.IDInvalid, false))
+			wc := d.GetOrDie(d.Insert(na.ID(), nd.ID(), nb.ID(), false))
+			wd := d.GetOrDie(d.Insert(nc.ID(), nf.ID(), ng.ID(), false))
+			we := d.GetOrDie(d.Insert(na.ID(), nh.ID(), ni.ID(), false))
+			wf := d.GetOrDie(d.Insert(nd.ID(), cid.IDInvalid, cid.IDInvalid, false))
+			wg := d.GetOrDie(d.Insert(nd.ID(), cid.IDInvalid, cid.IDInvalid, false))
+			wh := d.GetOrDie(d.Insert(ne.ID(), cid.IDInvalid, cid.IDInvalid, false))
+			wi := d.GetOrDie(d.Insert(ne.ID(), cid.IDInvalid, cid.IDInvalid, false))
+
+			wb.Leaves()[100] = struct{}{}
+			wf.Leaves()[101] = struct{}{}
+			wg.Leaves()[102] = struct{}{}
+			wh.Leaves()[103] = struct{}{}
+			wi.Leaves()[104] = struct{}{}
+
+			for _, n := range []node.N{wi, wh, wg, wf, we, wd, wb, wc, wa} {
+				node.SetAABB(n, data, nil, 1)
+				node.SetHeight(n)
+			}
+
+			return config{
+				name: "Swap/BE/EqualHeight",
+				x:    na,
+				want: wa,
+			}
+		}(),
 	}
 
 	for _, c := range configs {
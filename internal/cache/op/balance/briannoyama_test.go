@@ -73,7 +73,7 @@ func TestBrianNoyama(t *testing.T) {
 			r.Leaves()[101] = struct{}{}
 
 			for _, n := range []node.N{r, l, x} {
-				node.SetAABB(n, data, 1)
+				node.SetAABB(n, data, nil, 1)
 				node.SetHeight(n)
 			}
 
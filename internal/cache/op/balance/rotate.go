@@ -5,7 +5,6 @@ import (
 
 	"github.com/downflux/go-bvh/internal/cache/node"
 	"github.com/downflux/go-bvh/internal/cache/op/unsafe"
-	"github.com/downflux/go-bvh/internal/heuristic"
 	"github.com/downflux/go-geometry/nd/hyperrectangle"
 	"github.com/downflux/go-geometry/nd/vector"
 )
@@ -139,7 +138,7 @@ func rotate(x node.N, df bool) node.N {
 	case rtypeBF:
 		unsafe.Swap(r.source, r.target)
 
-		node.SetAABB(r.source.Parent(), nil, 1)
+		node.SetAABB(r.source.Parent(), nil, nil, 1)
 		node.SetHeight(r.source.Parent())
 
 		// The AABB of the local root has not changed, so skip
@@ -148,8 +147,8 @@ func rotate(x node.N, df bool) node.N {
 	case rtypeDF:
 		unsafe.Swap(r.source, r.target)
 
-		node.SetAABB(r.source.Parent(), nil, 1)
-		node.SetAABB(r.target.Parent(), nil, 1)
+		node.SetAABB(r.source.Parent(), nil, nil, 1)
+		node.SetAABB(r.target.Parent(), nil, nil, 1)
 		node.SetHeight(r.source.Parent())
 		node.SetHeight(r.target.Parent())
 
@@ -178,7 +177,7 @@ func mergeBF(b node.N, f node.N, g node.N, buf hyperrectangle.M) (bool, float64)
 	buf.Copy(b.AABB().R())
 	buf.Union(g.AABB().R())
 
-	h := f.Heuristic() + heuristic.H(buf.R())
+	h := f.Heuristic() + f.H(buf.R())
 	return true, h
 }
 
@@ -201,12 +200,12 @@ func mergeDF(d node.N, e node.N, f node.N, g node.N, buf hyperrectangle.M) (bool
 	buf.Copy(e.AABB().R())
 	buf.Union(f.AABB().R())
 
-	h := heuristic.H(buf.R())
+	h := d.H(buf.R())
 
 	buf.Copy(d.AABB().R())
 	buf.Union(g.AABB().R())
 
-	h += heuristic.H(buf.R())
+	h += d.H(buf.R())
 
 	return true, h
 }
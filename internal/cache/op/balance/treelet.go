@@ -0,0 +1,178 @@
+package balance
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+
+	"github.com/downflux/go-bvh/internal/cache/branch"
+	"github.com/downflux/go-bvh/internal/cache/node"
+	"github.com/downflux/go-geometry/nd/hyperrectangle"
+	"github.com/downflux/go-geometry/nd/vector"
+)
+
+const (
+	// TreeletSizeMin and TreeletSizeMax are the supported bounds on the
+	// number of treelet leaves. As the optimal topology search is
+	// exponential in the treelet size, Karras and Aila 2013 recommend a
+	// treelet size of at most seven.
+	TreeletSizeMin = 3
+	TreeletSizeMax = 8
+)
+
+// Treelet returns a tree cost minimization op which restructures the treelet
+// rooted at the input node into the topology which minimizes the total
+// heuristic of the treelet internal nodes, as per Karras and Aila 2013.
+//
+// The treelet is formed by repeatedly expanding the treelet leaf with the
+// largest heuristic, until the treelet has (up to) size leaves. Here, a treelet
+// leaf may be either a leaf node or the root of an arbitrary subtree. The
+// optimal topology is then found via dynamic programming over all subsets of
+// the treelet leaves.
+//
+// Unlike Rotate, the restructured treelet is not guaranteed to preserve the
+// AVL balance of the tree.
+//
+// The returned op reuses an internal buffer across calls, and so must not be
+// called concurrently.
+func Treelet(size int) B {
+	if size < TreeletSizeMin || size > TreeletSizeMax {
+		panic(fmt.Sprintf("invalid treelet size %v", size))
+	}
+
+	t := &treelet{
+		leaves:   make([]node.N, 0, size),
+		internal: make([]node.N, 0, size-1),
+		cost:     make([]float64, 1<<size),
+		split:    make([]int, 1<<size),
+		aabbs:    make([]hyperrectangle.M, 1<<size),
+	}
+	return func(n node.N) node.N { return t.restructure(n, size) }
+}
+
+// AVLTreelet returns an op which enforces the AVL height constraint, and then
+// restructures the local treelet as per Treelet. As the restructuring may
+// undo the AVL rotation, the tree heights are only loosely bounded.
+func AVLTreelet(size int) B {
+	f := Treelet(size)
+	return func(n node.N) node.N { return f(AVL(n)) }
+}
+
+type treelet struct {
+	leaves   []node.N
+	internal []node.N
+
+	// cost, split, and aabbs are indexed by the bitmask of a subset of
+	// the treelet leaves, and track the minimum total heuristic of the
+	// internal nodes of the subset, the optimal partition of the subset,
+	// and the bounding box of the subset respectively.
+	cost  []float64
+	split []int
+	aabbs []hyperrectangle.M
+}
+
+func (t *treelet) restructure(n node.N, size int) node.N {
+	if n.IsLeaf() {
+		return n
+	}
+
+	t.leaves = append(t.leaves[:0], n.Left(), n.Right())
+	t.internal = append(t.internal[:0], n)
+
+	for len(t.leaves) < size {
+		j := -1
+		for i, m := range t.leaves {
+			if !m.IsLeaf() && (j < 0 || m.Heuristic() > t.leaves[j].Heuristic()) {
+				j = i
+			}
+		}
+		if j < 0 {
+			break
+		}
+
+		m := t.leaves[j]
+		t.internal = append(t.internal, m)
+		t.leaves[j] = m.Left()
+		t.leaves = append(t.leaves, m.Right())
+	}
+	if len(t.leaves) < TreeletSizeMin {
+		return n
+	}
+
+	k := n.AABB().Min().Dimension()
+	if t.aabbs[0].Min().Dimension() != k {
+		for i := range t.aabbs {
+			t.aabbs[i] = hyperrectangle.New(
+				vector.V(make([]float64, k)),
+				vector.V(make([]float64, k)),
+			).M()
+		}
+	}
+
+	full := 1<<len(t.leaves) - 1
+	for s := 1; s <= full; s++ {
+		low := s & -s
+		if s == low {
+			t.aabbs[s].Copy(t.leaves[bits.TrailingZeros(uint(s))].AABB().R())
+			t.cost[s] = 0
+			continue
+		}
+
+		t.aabbs[s].Copy(t.aabbs[s^low].R())
+		t.aabbs[s].Union(t.aabbs[low].R())
+
+		// Only consider the partitions which place the lowest leaf in
+		// the first subset, as the tree is invariant under reflection.
+		c := math.Inf(1)
+		for p := (s - 1) & s; p > 0; p = (p - 1) & s {
+			if p&low == 0 {
+				continue
+			}
+			if d := t.cost[p] + t.cost[s^p]; d < c {
+				c = d
+				t.split[s] = p
+			}
+		}
+		t.cost[s] = c + n.H(t.aabbs[s].R())
+	}
+
+	var h float64
+	for _, m := range t.internal {
+		h += m.Heuristic()
+	}
+	if !(t.cost[full] < h) {
+		return n
+	}
+
+	t.link(n, full, t.internal[1:])
+	return n
+}
+
+// link recursively rebuilds the treelet subset s under the internal node n,
+// and returns the remaining unused internal treelet nodes.
+func (t *treelet) link(n node.N, s int, pool []node.N) []node.N {
+	p := t.split[s]
+	for _, c := range []struct {
+		b branch.B
+		s int
+	}{
+		{b: branch.BLeft, s: p},
+		{b: branch.BRight, s: s ^ p},
+	} {
+		var m node.N
+		if c.s&(c.s-1) == 0 {
+			m = t.leaves[bits.TrailingZeros(uint(c.s))]
+		} else {
+			m, pool = pool[len(pool)-1], pool[:len(pool)-1]
+			pool = t.link(m, c.s, pool)
+		}
+
+		n.SetChild(c.b, m.ID())
+		m.SetParent(n.ID())
+	}
+
+	node.SetAABB(n, nil, nil, 1)
+	node.SetHeight(n)
+
+	return pool
+}
@@ -0,0 +1,146 @@
+package balance
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/downflux/go-bvh/id"
+	"github.com/downflux/go-bvh/internal/cache"
+	"github.com/downflux/go-bvh/internal/cache/node"
+	"github.com/downflux/go-bvh/internal/cache/node/util"
+	"github.com/downflux/go-geometry/nd/hyperrectangle"
+	"github.com/downflux/go-geometry/nd/vector"
+
+	cid "github.com/downflux/go-bvh/internal/cache/id"
+)
+
+var (
+	_ B = Treelet(TreeletSizeMin)
+	_ B = AVLTreelet(TreeletSizeMax)
+)
+
+// chain generates a maximally unbalanced tree, where the leaf at depth i
+// contains the object with ID i.
+//
+//	  A
+//	 / \
+//	0   B
+//	   / \
+//	  1   C
+//	     / \
+//	    2   3
+func chain(data map[id.ID]hyperrectangle.R) (*cache.C, node.N) {
+	c := cache.New(cache.O{
+		LeafSize: 1,
+		K:        2,
+	})
+
+	n := len(data)
+	p := cid.IDInvalid
+
+	var root node.N
+	var internal []node.N
+	for i := 0; i < n-1; i++ {
+		m := c.GetOrDie(c.Insert(p, cid.IDInvalid, cid.IDInvalid, true))
+		l := c.GetOrDie(c.Insert(m.ID(), cid.IDInvalid, cid.IDInvalid, true))
+		l.Leaves()[id.ID(i)] = struct{}{}
+		node.SetAABB(l, data, nil, 1)
+
+		m.SetLeft(l.ID())
+		if i == 0 {
+			root = m
+		} else {
+			c.GetOrDie(p).SetRight(m.ID())
+		}
+		internal = append(internal, m)
+		p = m.ID()
+	}
+	l := c.GetOrDie(c.Insert(p, cid.IDInvalid, cid.IDInvalid, true))
+	l.Leaves()[id.ID(n-1)] = struct{}{}
+	node.SetAABB(l, data, nil, 1)
+	c.GetOrDie(p).SetRight(l.ID())
+
+	for i := len(internal) - 1; i >= 0; i-- {
+		node.SetAABB(internal[i], nil, nil, 1)
+		node.SetHeight(internal[i])
+	}
+	return c, root
+}
+
+func cost(n node.N) float64 {
+	var h float64
+	util.PreOrder(n, func(m node.N) {
+		if !m.IsLeaf() {
+			h += m.Heuristic()
+		}
+	})
+	return h
+}
+
+func TestTreelet(t *testing.T) {
+	t.Run("Optimal", func(t *testing.T) {
+		data := map[id.ID]hyperrectangle.R{
+			0: *hyperrectangle.New(vector.V{0, 0}, vector.V{1, 1}),
+			1: *hyperrectangle.New(vector.V{100, 0}, vector.V{101, 1}),
+			2: *hyperrectangle.New(vector.V{1, 0}, vector.V{2, 1}),
+			3: *hyperrectangle.New(vector.V{101, 0}, vector.V{102, 1}),
+		}
+		_, root := chain(data)
+		if got := Treelet(4)(root); got != root {
+			t.Fatalf("Treelet() = %v, want = %v", got.ID(), root.ID())
+		}
+
+		for _, m := range []node.N{root.Left(), root.Right()} {
+			if m.IsLeaf() {
+				t.Fatalf("Treelet() generated a root with leaf child %v", m.ID())
+			}
+			a := m.Left().Leaves()
+			b := m.Right().Leaves()
+			for x := range a {
+				for y := range b {
+					if x%2 != y%2 {
+						t.Errorf("Treelet() paired distant objects %v and %v", x, y)
+					}
+				}
+			}
+		}
+		if root.Height() != 2 {
+			t.Errorf("Height() = %v, want = %v", root.Height(), 2)
+		}
+	})
+
+	for _, size := range []int{TreeletSizeMin, 5, 7, TreeletSizeMax} {
+		t.Run(fmt.Sprintf("Random/Size=%v", size), func(t *testing.T) {
+			r := rand.New(rand.NewSource(0))
+			data := map[id.ID]hyperrectangle.R{}
+			for i := 0; i < 50; i++ {
+				x, y := 100*r.Float64(), 100*r.Float64()
+				data[id.ID(i)] = *hyperrectangle.New(vector.V{x, y}, vector.V{x + 1, y + 1})
+			}
+
+			c, root := chain(data)
+			before := cost(root)
+
+			f := Treelet(size)
+			util.PostOrder(root, func(n node.N) {
+				if !n.IsLeaf() {
+					f(n)
+					node.SetHeight(n)
+				}
+			})
+
+			if err := util.Validate(c, data, root); err != nil {
+				t.Fatalf("Validate() = %v, want = nil", err)
+			}
+			n := 0
+			util.PreOrder(root, func(m node.N) { n += len(m.Leaves()) })
+			if n != len(data) {
+				t.Errorf("Treelet() generated a tree with %v objects, want = %v", n, len(data))
+			}
+			if after := cost(root); after >= before {
+				t.Errorf("cost() = %v, want < %v", after, before)
+			}
+		})
+	}
+}
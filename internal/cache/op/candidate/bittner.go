@@ -6,7 +6,6 @@ import (
 	"github.com/downflux/go-bvh/internal/cache"
 	"github.com/downflux/go-bvh/internal/cache/node"
 	"github.com/downflux/go-bvh/internal/cache/op/unsafe"
-	"github.com/downflux/go-bvh/internal/heuristic"
 	"github.com/downflux/go-geometry/nd/hyperrectangle"
 	"github.com/downflux/go-geometry/nd/vector"
 	"github.com/downflux/go-pq/pq"
@@ -24,13 +23,21 @@ func Bittner(c *cache.C, n node.N, aabb hyperrectangle.R) node.N {
 	return m
 }
 
+// BittnerSibling returns the node which minimizes the total tree cost if it
+// were paired with a new sibling node of the input AABB. Unlike Bittner, this
+// function does not mutate the tree, and the returned node may be an internal
+// node. This is useful for reinserting entire subtrees.
+func BittnerSibling(c *cache.C, n node.N, aabb hyperrectangle.R) node.N {
+	return bittnerRO(c, n, aabb)
+}
+
 func bittnerRO(c *cache.C, n node.N, aabb hyperrectangle.R) node.N {
 	buf := hyperrectangle.New(
 		vector.V(make([]float64, c.K())),
 		vector.V(make([]float64, c.K())),
 	).M()
 
-	l := heuristic.H(aabb)
+	l := c.H(aabb)
 
 	// The priority queue q weight is the induced cost, i.e. the cost of
 	// traveling to the associated node. We pop the lowest-cost nodes first,
@@ -68,7 +75,7 @@ func bittnerRO(c *cache.C, n node.N, aabb hyperrectangle.R) node.N {
 		buf.Copy(m.AABB().R())
 		buf.Union(aabb)
 
-		direct := heuristic.H(buf.R())
+		direct := c.H(buf.R())
 		cost := induced + direct
 
 		if cost < h {
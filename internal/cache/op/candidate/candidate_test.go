@@ -20,6 +20,7 @@ var (
 		"Catto":       cattoRO,
 		"Guttman":     Guttman,
 		"DHConnelly":  dhconnellyRO,
+		"RStar":       RStar,
 	}
 )
 
@@ -4,7 +4,6 @@ import (
 	"github.com/downflux/go-bvh/internal/cache"
 	"github.com/downflux/go-bvh/internal/cache/node"
 	"github.com/downflux/go-bvh/internal/cache/op/unsafe"
-	"github.com/downflux/go-bvh/internal/heuristic"
 	"github.com/downflux/go-geometry/nd/hyperrectangle"
 	"github.com/downflux/go-geometry/nd/vector"
 )
@@ -43,13 +42,13 @@ func cattoRO(c *cache.C, n node.N, aabb hyperrectangle.R) node.N {
 		vector.V(make([]float64, c.K())),
 	).M()
 
-	g := heuristic.H(aabb)
+	g := c.H(aabb)
 
 	var m node.N
 	for m = n; !m.IsLeaf(); {
 		buf.Copy(aabb)
 		buf.Union(m.AABB().R())
-		combined := heuristic.H(buf.R())
+		combined := c.H(buf.R())
 
 		h := 2 * combined
 		inherited := 2 * (combined - g)
@@ -60,17 +59,17 @@ func cattoRO(c *cache.C, n node.N, aabb hyperrectangle.R) node.N {
 		buf.Copy(aabb)
 		buf.Union(m.Left().AABB().R())
 		if m.Left().IsLeaf() {
-			lh = heuristic.H(buf.R()) + inherited
+			lh = c.H(buf.R()) + inherited
 		} else {
-			lh = heuristic.H(buf.R()) - m.Left().Heuristic() + inherited
+			lh = c.H(buf.R()) - m.Left().Heuristic() + inherited
 		}
 
 		buf.Copy(aabb)
 		buf.Union(m.Right().AABB().R())
 		if m.Right().IsLeaf() {
-			rh = heuristic.H(buf.R()) + inherited
+			rh = c.H(buf.R()) + inherited
 		} else {
-			rh = heuristic.H(buf.R()) - m.Right().Heuristic() + inherited
+			rh = c.H(buf.R()) - m.Right().Heuristic() + inherited
 		}
 
 		if h < lh && h < rh {
@@ -4,7 +4,6 @@ import (
 	"github.com/downflux/go-bvh/internal/cache"
 	"github.com/downflux/go-bvh/internal/cache/node"
 	"github.com/downflux/go-bvh/internal/cache/op/unsafe"
-	"github.com/downflux/go-bvh/internal/heuristic"
 	"github.com/downflux/go-geometry/epsilon"
 	"github.com/downflux/go-geometry/nd/hyperrectangle"
 	"github.com/downflux/go-geometry/nd/vector"
@@ -37,7 +36,7 @@ func dhconnellyRecursive(n node.N, aabb hyperrectangle.R, buf hyperrectangle.M)
 	buf.Copy(aabb)
 	buf.Union(n.Left().AABB().R())
 
-	lh := heuristic.H(buf.R())
+	lh := n.H(buf.R())
 
 	diff := lh - n.Left().Heuristic()
 	opt := n.Left()
@@ -45,7 +44,7 @@ func dhconnellyRecursive(n node.N, aabb hyperrectangle.R, buf hyperrectangle.M)
 	buf.Copy(aabb)
 	buf.Union(n.Right().AABB().R())
 
-	rh := heuristic.H(buf.R())
+	rh := n.H(buf.R())
 
 	if d := rh - n.Right().Heuristic(); d < diff || (epsilon.Within(d, diff) && n.Left().Height() < opt.Height()) {
 		opt = n.Right()
@@ -3,7 +3,6 @@ package candidate
 import (
 	"github.com/downflux/go-bvh/internal/cache"
 	"github.com/downflux/go-bvh/internal/cache/node"
-	"github.com/downflux/go-bvh/internal/heuristic"
 	"github.com/downflux/go-geometry/epsilon"
 	"github.com/downflux/go-geometry/nd/hyperrectangle"
 	"github.com/downflux/go-geometry/nd/vector"
@@ -27,13 +26,13 @@ func Guttman(c *cache.C, n node.N, aabb hyperrectangle.R) node.N {
 		buf.Copy(aabb)
 		buf.Union(l.AABB().R())
 
-		lh := heuristic.H(buf.R())
+		lh := c.H(buf.R())
 		dlh := lh - l.Heuristic()
 
 		buf.Copy(aabb)
 		buf.Union(r.AABB().R())
 
-		rh := heuristic.H(buf.R())
+		rh := c.H(buf.R())
 		drh := rh - r.Heuristic()
 
 		// Choose an appropriate node to search. Use the node with the
@@ -3,7 +3,6 @@ package perf
 import (
 	"github.com/downflux/go-bvh/internal/cache"
 	"github.com/downflux/go-bvh/internal/cache/node"
-	"github.com/downflux/go-bvh/internal/heuristic"
 	"github.com/downflux/go-geometry/nd/hyperrectangle"
 	"github.com/downflux/go-geometry/nd/vector"
 
@@ -20,7 +19,7 @@ func Trivial() (*cache.C, node.N) {
 
 	root := c.GetOrDie(c.Insert(cid.IDInvalid, cid.IDInvalid, cid.IDInvalid, true))
 	root.AABB().Copy(*hyperrectangle.New(vector.V{0, 0}, vector.V{1, 1}))
-	root.SetHeuristic(heuristic.H(root.AABB().R()))
+	root.SetHeuristic(c.H(root.AABB().R()))
 
 	return c, root
 }
@@ -36,7 +35,7 @@ func Balanced(n int) (*cache.C, node.N) {
 	for i := 0; i < n; i++ {
 		l := c.GetOrDie(c.Insert(cid.IDInvalid, cid.IDInvalid, cid.IDInvalid, true))
 		l.AABB().Copy(*hyperrectangle.New(vector.V{float64(i), 0}, vector.V{float64(i) + 1, 1}))
-		l.SetHeuristic(heuristic.H(l.AABB().R()))
+		l.SetHeuristic(c.H(l.AABB().R()))
 
 		horizon = append(horizon, l)
 	}
@@ -48,7 +47,7 @@ func Balanced(n int) (*cache.C, node.N) {
 			r := horizon[i+1]
 
 			p := c.GetOrDie(c.Insert(cid.IDInvalid, l.ID(), r.ID(), true))
-			node.SetAABB(p, nil, 1)
+			node.SetAABB(p, nil, nil, 1)
 			node.SetHeight(p)
 
 			l.SetParent(p.ID())
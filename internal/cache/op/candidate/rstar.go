@@ -0,0 +1,95 @@
+package candidate
+
+import (
+	"github.com/downflux/go-bvh/internal/cache"
+	"github.com/downflux/go-bvh/internal/cache/node"
+	"github.com/downflux/go-geometry/epsilon"
+	"github.com/downflux/go-geometry/nd/hyperrectangle"
+	"github.com/downflux/go-geometry/nd/vector"
+)
+
+// RStar finds an existing candidate leaf node to insert the AABB, as per the
+// ChooseSubtree function in Beckmann et al. 1990.
+//
+// Directly above the leaf nodes, we descend into the child whose overlap with
+// its sibling increases the least after adding the AABB, as overlapping leaf
+// nodes are the main source of redundant broad-phase tests. Higher up the
+// tree, we descend into the child with the least increase in cost, as per
+// Guttman. In both cases, ties are broken by the lesser increase in cost, and
+// then by the smaller resultant cost.
+//
+// Overlap is measured by the volume of the intersection of the two AABBs,
+// rather than the tree heuristic, as two AABBs which only touch should not be
+// considered to overlap.
+func RStar(c *cache.C, n node.N, aabb hyperrectangle.R) node.N {
+	k := aabb.Min().Dimension()
+	buf := hyperrectangle.New(
+		vector.V(make([]float64, k)),
+		vector.V(make([]float64, k)),
+	).M()
+	lbuf := hyperrectangle.New(
+		vector.V(make([]float64, k)),
+		vector.V(make([]float64, k)),
+	).M()
+	rbuf := hyperrectangle.New(
+		vector.V(make([]float64, k)),
+		vector.V(make([]float64, k)),
+	).M()
+
+	var m node.N
+	for m = n; !m.IsLeaf(); {
+		l, r := m.Left(), m.Right()
+
+		lbuf.Copy(aabb)
+		lbuf.Union(l.AABB().R())
+		lh := c.H(lbuf.R())
+		dlh := lh - l.Heuristic()
+
+		rbuf.Copy(aabb)
+		rbuf.Union(r.AABB().R())
+		rh := c.H(rbuf.R())
+		drh := rh - r.Heuristic()
+
+		var dlo, dro float64
+		if l.IsLeaf() || r.IsLeaf() {
+			o := overlap(l.AABB().R(), r.AABB().R(), buf)
+			dlo = overlap(lbuf.R(), r.AABB().R(), buf) - o
+			dro = overlap(rbuf.R(), l.AABB().R(), buf) - o
+		}
+
+		switch {
+		case !epsilon.Within(dlo, dro):
+			if dlo < dro {
+				m = l
+			} else {
+				m = r
+			}
+		case dlh < drh || epsilon.Within(dlh, drh) && lh < rh:
+			m = l
+		default:
+			m = r
+		}
+	}
+
+	return m
+}
+
+// overlap returns the volume of the intersection of the two input AABBs. The
+// input buffer is used to calculate the intersection.
+func overlap(r hyperrectangle.R, s hyperrectangle.R, buf hyperrectangle.M) float64 {
+	bmin, bmax := buf.Min(), buf.Max()
+	for i := vector.D(0); i < r.Min().Dimension(); i++ {
+		bmin[i] = r.Min()[i]
+		if s.Min()[i] > bmin[i] {
+			bmin[i] = s.Min()[i]
+		}
+		bmax[i] = r.Max()[i]
+		if s.Max()[i] < bmax[i] {
+			bmax[i] = s.Max()[i]
+		}
+		if bmin[i] >= bmax[i] {
+			return 0
+		}
+	}
+	return hyperrectangle.V(buf.R())
+}
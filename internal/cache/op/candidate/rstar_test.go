@@ -0,0 +1,160 @@
+package candidate
+
+import (
+	"testing"
+
+	"github.com/downflux/go-bvh/internal/cache"
+	"github.com/downflux/go-bvh/internal/cache/node"
+	"github.com/downflux/go-bvh/internal/cache/node/util/cmp"
+	"github.com/downflux/go-bvh/internal/heuristic"
+	"github.com/downflux/go-geometry/nd/hyperrectangle"
+	"github.com/downflux/go-geometry/nd/vector"
+
+	cid "github.com/downflux/go-bvh/internal/cache/id"
+)
+
+var (
+	_ C = RStar
+)
+
+func TestRStar(t *testing.T) {
+	type config struct {
+		name string
+		c    *cache.C
+		n    node.N
+		aabb hyperrectangle.R
+		want node.N
+	}
+
+	configs := []config{
+		func() config {
+			c := cache.New(cache.O{
+				LeafSize: 1,
+				K:        2,
+			})
+
+			root := c.GetOrDie(c.Insert(cid.IDInvalid, cid.IDInvalid, cid.IDInvalid, true))
+			root.AABB().Copy(*hyperrectangle.New(vector.V{0, 0}, vector.V{1, 1}))
+			root.SetHeuristic(heuristic.H(root.AABB().R()))
+
+			return config{
+				name: "Root",
+				c:    c,
+				n:    root,
+				aabb: *hyperrectangle.New(vector.V{2, 2}, vector.V{3, 3}),
+				want: root,
+			}
+		}(),
+	}
+	configs = append(configs, func() []config {
+		c := cache.New(cache.O{
+			LeafSize: 1,
+			K:        2,
+		})
+
+		root := c.GetOrDie(c.Insert(cid.IDInvalid, cid.IDInvalid, cid.IDInvalid, true))
+		left := c.GetOrDie(c.Insert(root.ID(), cid.IDInvalid, cid.IDInvalid, true))
+		right := c.GetOrDie(c.Insert(root.ID(), cid.IDInvalid, cid.IDInvalid, true))
+
+		root.SetLeft(left.ID())
+		root.SetRight(right.ID())
+
+		root.SetHeight(1)
+
+		root.AABB().Copy(*hyperrectangle.New(vector.V{0, 0}, vector.V{12, 10}))
+		left.AABB().Copy(*hyperrectangle.New(vector.V{0, 0}, vector.V{10, 10}))
+		right.AABB().Copy(*hyperrectangle.New(vector.V{11, 0}, vector.V{12, 1}))
+
+		root.SetHeuristic(heuristic.H(root.AABB().R()))
+		left.SetHeuristic(heuristic.H(left.AABB().R()))
+		right.SetHeuristic(heuristic.H(right.AABB().R()))
+
+		return []config{
+			// Check that the node with the lesser overlap
+			// enlargement is chosen, even though this node has a
+			// larger increase in cost.
+			config{
+				name: "Leaf/Overlap",
+				c:    c,
+				n:    root,
+				aabb: *hyperrectangle.New(vector.V{10.5, 9}, vector.V{11.5, 10}),
+				want: right,
+			},
+			// Check that the node with the lesser increase in cost
+			// is chosen if neither node increases the overlap.
+			config{
+				name: "Leaf/NoOverlap",
+				c:    c,
+				n:    root,
+				aabb: *hyperrectangle.New(vector.V{1, 1}, vector.V{2, 2}),
+				want: left,
+			},
+		}
+	}()...)
+	configs = append(configs, func() config {
+		c := cache.New(cache.O{
+			LeafSize: 1,
+			K:        2,
+		})
+
+		//      root
+		//     /    \
+		//    a      b
+		//   / \    / \
+		//  d   e  f   g
+		root := c.GetOrDie(c.Insert(cid.IDInvalid, cid.IDInvalid, cid.IDInvalid, true))
+		a := c.GetOrDie(c.Insert(root.ID(), cid.IDInvalid, cid.IDInvalid, true))
+		b := c.GetOrDie(c.Insert(root.ID(), cid.IDInvalid, cid.IDInvalid, true))
+		d := c.GetOrDie(c.Insert(a.ID(), cid.IDInvalid, cid.IDInvalid, true))
+		e := c.GetOrDie(c.Insert(a.ID(), cid.IDInvalid, cid.IDInvalid, true))
+		f := c.GetOrDie(c.Insert(b.ID(), cid.IDInvalid, cid.IDInvalid, true))
+		g := c.GetOrDie(c.Insert(b.ID(), cid.IDInvalid, cid.IDInvalid, true))
+
+		root.SetLeft(a.ID())
+		root.SetRight(b.ID())
+		a.SetLeft(d.ID())
+		a.SetRight(e.ID())
+		b.SetLeft(f.ID())
+		b.SetRight(g.ID())
+
+		root.SetHeight(2)
+		a.SetHeight(1)
+		b.SetHeight(1)
+
+		for _, s := range []struct {
+			n    node.N
+			aabb hyperrectangle.R
+		}{
+			{n: root, aabb: *hyperrectangle.New(vector.V{0, 0}, vector.V{12, 10})},
+			{n: a, aabb: *hyperrectangle.New(vector.V{0, 0}, vector.V{10, 10})},
+			{n: b, aabb: *hyperrectangle.New(vector.V{11, 0}, vector.V{12, 1})},
+			{n: d, aabb: *hyperrectangle.New(vector.V{0, 0}, vector.V{1, 1})},
+			{n: e, aabb: *hyperrectangle.New(vector.V{9, 9}, vector.V{10, 10})},
+			{n: f, aabb: *hyperrectangle.New(vector.V{11, 0}, vector.V{12, 0.5})},
+			{n: g, aabb: *hyperrectangle.New(vector.V{11, 0.5}, vector.V{12, 1})},
+		} {
+			s.n.AABB().Copy(s.aabb)
+			s.n.SetHeuristic(heuristic.H(s.aabb))
+		}
+
+		// Check that the overlap criterion is not used for internal
+		// nodes which are not directly above the leaves. Here, the
+		// input AABB overlaps b after being added to a, but a has a
+		// much smaller increase in cost.
+		return config{
+			name: "Internal/Cost",
+			c:    c,
+			n:    root,
+			aabb: *hyperrectangle.New(vector.V{10.5, 9}, vector.V{11.5, 10}),
+			want: e,
+		}
+	}())
+
+	for _, c := range configs {
+		t.Run(c.name, func(t *testing.T) {
+			if got := RStar(c.c, c.n, c.aabb); !cmp.Equal(got, c.want) {
+				t.Errorf("RStar() = %v, want = %v", got, c.want)
+			}
+		})
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"github.com/downflux/go-bvh/id"
 	"github.com/downflux/go-bvh/internal/cache"
 	"github.com/downflux/go-bvh/internal/cache/node"
-	"github.com/downflux/go-bvh/internal/heuristic"
 	"github.com/downflux/go-geometry/nd/hyperrectangle"
 	"github.com/downflux/go-geometry/nd/vector"
 )
@@ -19,11 +18,9 @@ import (
 // rtreego which takes this into consideration is not implemented here.
 func DHConnelly(c *cache.C, data map[id.ID]hyperrectangle.R, n node.N, m node.N) {
 	if c.LeafSize() == 1 {
-		for x := range n.Leaves() {
-			m.Leaves()[x] = struct{}{}
-			delete(n.Leaves(), x)
-			break
-		}
+		x := last(n)
+		m.Leaves()[x] = struct{}{}
+		delete(n.Leaves(), x)
 		return
 	}
 
@@ -32,10 +29,7 @@ func DHConnelly(c *cache.C, data map[id.ID]hyperrectangle.R, n node.N, m node.N)
 		vector.V(make([]float64, c.K())),
 	).M()
 
-	leaves := make([]id.ID, 0, len(n.Leaves()))
-	for x := range n.Leaves() {
-		leaves = append(leaves, x)
-	}
+	leaves := sorted(n)
 
 	// Reset the source node's leaves. We are skipping the same operation
 	// for the destination node as we expect that node to be empty.
@@ -43,15 +37,15 @@ func DHConnelly(c *cache.C, data map[id.ID]hyperrectangle.R, n node.N, m node.N)
 		delete(n.Leaves(), x)
 	}
 
-	li, ri := seed(data, leaves, buf)
+	li, ri := seed(c, data, leaves, buf)
 
 	// Use the AABB objects from the n and m nodes as buffers here.
 	n.Leaves()[leaves[li]] = struct{}{}
 	m.Leaves()[leaves[ri]] = struct{}{}
 	n.AABB().Copy(data[leaves[li]])
 	m.AABB().Copy(data[leaves[ri]])
-	n.SetHeuristic(heuristic.H(n.AABB().R()))
-	m.SetHeuristic(heuristic.H(m.AABB().R()))
+	n.SetHeuristic(c.H(n.AABB().R()))
+	m.SetHeuristic(c.H(m.AABB().R()))
 
 	remaining := append(leaves[:li], leaves[li+1:ri]...)
 	remaining = append(remaining, leaves[ri+1:]...)
@@ -63,7 +57,7 @@ func DHConnelly(c *cache.C, data map[id.ID]hyperrectangle.R, n node.N, m node.N)
 		p := group(aabb, n, m, buf)
 		p.Leaves()[remaining[ni]] = struct{}{}
 		p.AABB().Union(aabb)
-		p.SetHeuristic(heuristic.H(p.AABB().R()))
+		p.SetHeuristic(c.H(p.AABB().R()))
 
 		remaining = append(remaining[:ni], remaining[ni+1:]...)
 	}
@@ -77,11 +71,11 @@ func DHConnelly(c *cache.C, data map[id.ID]hyperrectangle.R, n node.N, m node.N)
 func group(aabb hyperrectangle.R, n node.N, m node.N, buf hyperrectangle.M) node.N {
 	buf.Copy(aabb)
 	buf.Union(n.AABB().R())
-	lh := heuristic.H(buf.R())
+	lh := n.H(buf.R())
 
 	buf.Copy(aabb)
 	buf.Union(m.AABB().R())
-	rh := heuristic.H(buf.R())
+	rh := m.H(buf.R())
 
 	ld := lh - n.Heuristic()
 	rd := rh - m.Heuristic()
@@ -122,11 +116,11 @@ func next(data map[id.ID]hyperrectangle.R, leaves []id.ID, n node.N, m node.N, b
 
 		buf.Copy(aabb)
 		buf.Union(n.AABB().R())
-		ld := heuristic.H(buf.R()) - n.Heuristic()
+		ld := n.H(buf.R()) - n.Heuristic()
 
 		buf.Copy(aabb)
 		buf.Union(m.AABB().R())
-		rd := heuristic.H(buf.R()) - m.Heuristic()
+		rd := m.H(buf.R()) - m.Heuristic()
 
 		if e := math.Abs(ld - rd); e > d {
 			d = e
@@ -138,7 +132,7 @@ func next(data map[id.ID]hyperrectangle.R, leaves []id.ID, n node.N, m node.N, b
 }
 
 // seed picks two initial leaf objects to be placed in the left and right nodes.
-func seed(data map[id.ID]hyperrectangle.R, leaves []id.ID, buf hyperrectangle.M) (int, int) {
+func seed(c *cache.C, data map[id.ID]hyperrectangle.R, leaves []id.ID, buf hyperrectangle.M) (int, int) {
 	var l, r int
 	h := math.Inf(-1)
 
@@ -147,7 +141,7 @@ func seed(data map[id.ID]hyperrectangle.R, leaves []id.ID, buf hyperrectangle.M)
 			buf.Copy(data[x])
 			buf.Union(data[y])
 
-			if g := heuristic.H(buf.R()) - (heuristic.H(data[x]) + heuristic.H(data[y])); g > h {
+			if g := c.H(buf.R()) - (c.H(data[x]) + c.H(data[y])); g > h {
 				h = g
 				l = i
 				r = j + i + 1
@@ -285,7 +285,10 @@ func TestSeed(t *testing.T) {
 	for _, c := range configs {
 		t.Run(c.name, func(t *testing.T) {
 			got := &w{}
-			got.l, got.r = seed(c.data, c.leaves, hyperrectangle.New(
+			got.l, got.r = seed(cache.New(cache.O{
+				K:        2,
+				LeafSize: len(c.leaves),
+			}), c.data, c.leaves, hyperrectangle.New(
 				vector.V(make([]float64, 2)),
 				vector.V(make([]float64, 2)),
 			).M())
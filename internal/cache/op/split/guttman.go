@@ -6,7 +6,6 @@ import (
 	"github.com/downflux/go-bvh/id"
 	"github.com/downflux/go-bvh/internal/cache"
 	"github.com/downflux/go-bvh/internal/cache/node"
-	"github.com/downflux/go-bvh/internal/heuristic"
 	"github.com/downflux/go-geometry/nd/hyperrectangle"
 	"github.com/downflux/go-geometry/nd/vector"
 )
@@ -22,17 +21,15 @@ import (
 // node.SetAABB().
 func GuttmanLinear(c *cache.C, data map[id.ID]hyperrectangle.R, n node.N, m node.N) {
 	if c.LeafSize() == 1 {
-		for x := range n.Leaves() {
-			m.Leaves()[x] = struct{}{}
-			delete(n.Leaves(), x)
-			break
-		}
+		x := last(n)
+		m.Leaves()[x] = struct{}{}
+		delete(n.Leaves(), x)
 		return
 	}
 
 	// Use the source node AABB as a scratch space to calculate the
 	// tightly-bound AABB.
-	node.SetAABB(n, data, 1)
+	node.SetAABB(n, data, nil, 1)
 	buf := hyperrectangle.New(
 		vector.V(make([]float64, c.K())),
 		vector.V(make([]float64, c.K())),
@@ -41,9 +38,8 @@ func GuttmanLinear(c *cache.C, data map[id.ID]hyperrectangle.R, n node.N, m node
 
 	// Reset the leaves within the source node, as data will be copied into
 	// here.
-	nodes := make([]id.ID, 0, len(n.Leaves()))
-	for x := range n.Leaves() {
-		nodes = append(nodes, x)
+	nodes := sorted(n)
+	for _, x := range nodes {
 		delete(n.Leaves(), x)
 	}
 
@@ -107,16 +103,16 @@ func GuttmanLinear(c *cache.C, data map[id.ID]hyperrectangle.R, n node.N, m node
 			continue
 		}
 
-		lh := heuristic.H(n.AABB().R())
-		rh := heuristic.H(m.AABB().R())
+		lh := c.H(n.AABB().R())
+		rh := c.H(m.AABB().R())
 
 		buf.Copy(n.AABB().R())
 		buf.Union(aabb)
-		dlh := heuristic.H(buf.R()) - lh
+		dlh := c.H(buf.R()) - lh
 
 		buf.Copy(m.AABB().R())
 		buf.Union(aabb)
-		drh := heuristic.H(buf.R()) - rh
+		drh := c.H(buf.R()) - rh
 
 		if dlh < drh {
 			n.Leaves()[x] = struct{}{}
@@ -64,7 +64,7 @@ func TestGuttmanLinear(t *testing.T) {
 
 	for _, c := range configs {
 		t.Run(c.name, func(t *testing.T) {
-			node.SetAABB(c.n, c.data, 1)
+			node.SetAABB(c.n, c.data, nil, 1)
 			h := heuristic.H(c.n.AABB().R())
 			nodes := []id.ID{}
 			for x := range c.n.Leaves() {
@@ -84,8 +84,8 @@ func TestGuttmanLinear(t *testing.T) {
 			})
 
 			t.Run(fmt.Sprintf("%s/H", c.name), func(t *testing.T) {
-				node.SetAABB(c.n, c.data, 1)
-				node.SetAABB(c.m, c.data, 1)
+				node.SetAABB(c.n, c.data, nil, 1)
+				node.SetAABB(c.m, c.data, nil, 1)
 
 				if got := heuristic.H(c.n.AABB().R()) + heuristic.H(c.m.AABB().R()); got > h {
 					t.Errorf("GuttmanLinear() did not decrease overall heuristic")
@@ -12,3 +12,31 @@ import (
 //
 // The source node tracks exactly c.LeafSize() + 1 objects.
 type S func(c *cache.C, data map[id.ID]hyperrectangle.R, n node.N, m node.N)
+
+// sorted returns the objects of the input leaf node, sorted by ID. Splitting
+// the objects in a fixed order ensures the resultant tree layout does not depend
+// on the (randomized) map iteration order.
+//
+// As leaf nodes are small, the objects are insertion sorted as they are added
+// to the output, which avoids the additional allocations of sort.Slice.
+func sorted(n node.N) []id.ID {
+	xs := make([]id.ID, 0, len(n.Leaves()))
+	for x := range n.Leaves() {
+		xs = append(xs, x)
+		for i := len(xs) - 1; i > 0 && xs[i] < xs[i-1]; i-- {
+			xs[i], xs[i-1] = xs[i-1], xs[i]
+		}
+	}
+	return xs
+}
+
+// last returns the object in the input leaf node with the largest ID.
+func last(n node.N) id.ID {
+	var y id.ID
+	for x := range n.Leaves() {
+		if x > y {
+			y = x
+		}
+	}
+	return y
+}
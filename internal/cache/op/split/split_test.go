@@ -13,6 +13,7 @@ import (
 	"github.com/downflux/go-bvh/perf/size"
 	"github.com/downflux/go-geometry/nd/hyperrectangle"
 	"github.com/downflux/go-geometry/nd/vector"
+	"github.com/google/go-cmp/cmp"
 
 	cid "github.com/downflux/go-bvh/internal/cache/id"
 )
@@ -28,6 +29,60 @@ var (
 	}
 )
 
+func TestSorted(t *testing.T) {
+	c := cache.New(cache.O{
+		LeafSize: 8,
+		K:        k,
+	})
+	n := c.GetOrDie(c.Insert(cid.IDInvalid, cid.IDInvalid, cid.IDInvalid, true))
+	for _, x := range []id.ID{105, 101, 108, 100, 103, 102, 107, 104, 106} {
+		n.Leaves()[x] = struct{}{}
+	}
+
+	want := []id.ID{100, 101, 102, 103, 104, 105, 106, 107, 108}
+	if diff := cmp.Diff(want, sorted(n)); diff != "" {
+		t.Errorf("sorted() mismatch (-want +got):\n%v", diff)
+	}
+	if got := last(n); got != 108 {
+		t.Errorf("last() = %v, want = %v", got, 108)
+	}
+
+	if got := testing.AllocsPerRun(100, func() { sorted(n) }); got != 1 {
+		t.Errorf("sorted() allocated %v times per run, want = %v", got, 1)
+	}
+}
+
+// TestAllocs checks that splitting a leaf node with a leaf size of one does not
+// allocate, as this is on the hot path for the default tree configuration.
+func TestAllocs(t *testing.T) {
+	data := map[id.ID]hyperrectangle.R{
+		100: *hyperrectangle.New(vector.V{0, 0}, vector.V{1, 1}),
+		101: *hyperrectangle.New(vector.V{2, 2}, vector.V{3, 3}),
+	}
+
+	for name, s := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := cache.New(cache.O{
+				LeafSize: 1,
+				K:        k,
+			})
+			n := c.GetOrDie(c.Insert(cid.IDInvalid, cid.IDInvalid, cid.IDInvalid, true))
+			m := c.GetOrDie(c.Insert(cid.IDInvalid, cid.IDInvalid, cid.IDInvalid, true))
+
+			f := func() {
+				for x := range data {
+					n.Leaves()[x] = struct{}{}
+					delete(m.Leaves(), x)
+				}
+				s(c, data, n, m)
+			}
+			if got := testing.AllocsPerRun(100, f); got != 0 {
+				t.Errorf("S() allocated %v times per run, want = %v", got, 0)
+			}
+		})
+	}
+}
+
 func BenchmarkS(b *testing.B) {
 	const j = 9
 
@@ -79,7 +134,7 @@ func BenchmarkS(b *testing.B) {
 						}
 						n.Leaves()[x] = struct{}{}
 					}
-					node.SetAABB(n, data, 1)
+					node.SetAABB(n, data, nil, 1)
 
 					return c, n, m
 				}()
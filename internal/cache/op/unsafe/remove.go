@@ -38,9 +38,8 @@ func Remove(c *cache.C, n node.N) node.N {
 
 			q.SetChild(q.Branch(p.ID()), m.ID())
 			m.SetParent(q.ID())
-
-			c.Delete(p.ID())
 		}
+		c.Delete(p.ID())
 	}
 
 	open := make([]node.N, 0, 128)
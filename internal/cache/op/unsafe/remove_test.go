@@ -100,9 +100,13 @@ func TestRemove(t *testing.T) {
 
 	for _, c := range configs {
 		t.Run(c.name, func(t *testing.T) {
+			p := c.n.Parent()
 			if got := Remove(c.c, c.n); !cmp.Equal(c.want, got) {
 				t.Errorf("Remove() = %v, want = %v", got, c.want)
 			}
+			if p != nil && c.c.IsAllocated(p.ID()) {
+				t.Errorf("Remove() did not free the parent node %v", p.ID())
+			}
 		})
 	}
 }
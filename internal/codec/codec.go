@@ -0,0 +1,122 @@
+// Package codec implements the little-endian primitives used in the binary
+// serialization of BVH trees.
+package codec
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// W is an append-only binary buffer.
+type W struct {
+	b []byte
+}
+
+func NewW(size int) *W { return &W{b: make([]byte, 0, size)} }
+
+func (w *W) Bytes() []byte { return w.b }
+
+func (w *W) Raw(b []byte)      { w.b = append(w.b, b...) }
+func (w *W) Uint64(x uint64)   { w.b = binary.LittleEndian.AppendUint64(w.b, x) }
+func (w *W) Int64(x int64)     { w.Uint64(uint64(x)) }
+func (w *W) Float64(x float64) { w.Uint64(math.Float64bits(x)) }
+
+func (w *W) Bool(x bool) {
+	var b byte
+	if x {
+		b = 1
+	}
+	w.b = append(w.b, b)
+}
+
+func (w *W) Float64s(x []float64) {
+	for _, f := range x {
+		w.Float64(f)
+	}
+}
+
+// R reads values from a binary buffer. Any read past the end of the buffer
+// will set a sticky error, and all subsequent reads will return zero values.
+// The caller should check R.Err after decoding.
+type R struct {
+	b   []byte
+	err error
+}
+
+func NewR(b []byte) *R { return &R{b: b} }
+
+func (r *R) Err() error { return r.err }
+
+// Len returns the number of unread bytes in the buffer.
+func (r *R) Len() int { return len(r.b) }
+
+// Errorf sets the sticky error if it is not already set.
+func (r *R) Errorf(format string, a ...any) {
+	if r.err == nil {
+		r.err = fmt.Errorf(format, a...)
+	}
+}
+
+func (r *R) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.b) < n {
+		r.Errorf("unexpected end of buffer: want %v bytes, got %v", n, len(r.b))
+		return nil
+	}
+	b := r.b[:n]
+	r.b = r.b[n:]
+	return b
+}
+
+func (r *R) Raw(n int) []byte { return r.next(n) }
+
+func (r *R) Bool() bool {
+	b := r.next(1)
+	if b == nil {
+		return false
+	}
+	switch b[0] {
+	case 0:
+		return false
+	case 1:
+		return true
+	default:
+		r.Errorf("invalid boolean value %v", b[0])
+		return false
+	}
+}
+
+func (r *R) Uint64() uint64 {
+	b := r.next(8)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(b)
+}
+
+func (r *R) Int64() int64     { return int64(r.Uint64()) }
+func (r *R) Float64() float64 { return math.Float64frombits(r.Uint64()) }
+
+func (r *R) Float64s(x []float64) {
+	for i := range x {
+		x[i] = r.Float64()
+	}
+}
+
+// Count reads a collection size, and checks that the remaining buffer is large
+// enough to hold the collection, given each element is encoded in at least
+// size bytes. This guards against large allocations from corrupted input.
+func (r *R) Count(size int) int {
+	n := r.Uint64()
+	if r.err != nil {
+		return 0
+	}
+	if n > uint64(len(r.b)/size) {
+		r.Errorf("invalid collection size %v", n)
+		return 0
+	}
+	return int(n)
+}
@@ -5,9 +5,14 @@ import (
 	"github.com/downflux/go-geometry/nd/vector"
 )
 
+// F is a node heuristic, i.e. the cost function used to compare node AABBs
+// during tree construction and rebalancing.
+type F func(r hyperrectangle.R) float64
+
 var (
-	// H is intended to be the SAH as defined in canonical literature.
-	H = hyperrectangle.SA
+	// H is intended to be the SAH as defined in canonical literature. This
+	// is the default node heuristic.
+	H F = hyperrectangle.SA
 )
 
 // The briannoyama implementation uses the total edge-weight of a hyperrectangle